@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// archiveYear contains all articles published in a single year. Each year
+// has its own page, while archive.html only lists the years and months.
+type archiveYear struct {
+	Year  int
+	File  string
+	Count int
+	// Months are sorted newest first and only contain months with articles.
+	Months []*archiveMonth
+}
+
+type archiveMonth struct {
+	Month time.Month
+	// Id is used as anchor on the page of the year.
+	Id       string
+	Count    int
+	Articles []*indexedArticle
+}
+
+type archivePageData struct {
+	blogConfig
+	CustomPages []*customPageEntry
+	Years       []*archiveYear
+	// Year is nil for the overview page.
+	Year *archiveYear
+}
+
+// groupArchive groups articles by year and month. The articles have to be
+// sorted newest first. The pages of the years are placed in the given
+// directory.
+func groupArchive(articles []*indexedArticle, directory string) []*archiveYear {
+	var years []*archiveYear
+	for _, article := range articles {
+		year, month := article.RFC3339Time.Year(), article.RFC3339Time.Month()
+
+		if len(years) == 0 || years[len(years)-1].Year != year {
+			years = append(years, &archiveYear{
+				Year: year,
+				File: fmt.Sprintf("%sarchive-%d.html", directory, year),
+			})
+		}
+		currentYear := years[len(years)-1]
+		currentYear.Count++
+
+		if len(currentYear.Months) == 0 || currentYear.Months[len(currentYear.Months)-1].Month != month {
+			currentYear.Months = append(currentYear.Months, &archiveMonth{
+				Month: month,
+				Id:    fmt.Sprintf("month-%02d", month),
+			})
+		}
+		currentMonth := currentYear.Months[len(currentYear.Months)-1]
+		currentMonth.Count++
+		currentMonth.Articles = append(currentMonth.Articles, article)
+	}
+
+	return years
+}
+
+// writeArchive writes archive.html and one page per year into the directory
+// of the language. The names of the written files are returned for the
+// sitemap.
+func writeArchive(
+	theme *theme,
+	articles []*indexedArticle,
+	data archivePageData,
+	outputDir string,
+	minifyOutput bool,
+	manifest *buildManifest,
+	sharedHash string,
+) ([]string, error) {
+	archiveTemplate := theme.templates.Lookup("archive")
+	data.Years = groupArchive(articles, data.LanguageDir)
+	data.Title = data.Strings["Archive"]
+
+	overviewFile := data.LanguageDir + "archive.html"
+	files := []string{overviewFile}
+	if !manifest.upToDate(overviewFile, hashInputs(sharedHash, data)) {
+		if err := writeTemplateToFile(archiveTemplate, data, outputDir, overviewFile, minifyOutput); err != nil {
+			return nil, fmt.Errorf("error writing archive: %w", err)
+		}
+	}
+
+	for _, year := range data.Years {
+		data.Year = year
+		data.Title = fmt.Sprintf(data.Strings["ArchiveYear"], year.Year)
+		files = append(files, year.File)
+		if manifest.upToDate(year.File, hashInputs(sharedHash, data)) {
+			continue
+		}
+
+		if err := writeTemplateToFile(archiveTemplate, data, outputDir, year.File, minifyOutput); err != nil {
+			return nil, fmt.Errorf("error writing archive for %d: %w", year.Year, err)
+		}
+	}
+
+	return files, nil
+}
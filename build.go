@@ -3,27 +3,24 @@ package main
 import (
 	"bytes"
 	"embed"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"hash/fnv"
 	"html/template"
 	"io"
+	"io/fs"
 	"log"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
-	"slices"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 
-	"github.com/Bios-Marcel/feeds"
+	"github.com/alecthomas/chroma/v2/styles"
 	"github.com/goccy/go-yaml"
-	"github.com/otiai10/copy"
 	"golang.org/x/net/html"
 )
 
@@ -35,7 +32,10 @@ type ArticleHeaders struct {
 	Description string `yaml:"description"`
 	Date        string `yaml:"date"`
 	dateParsed  time.Time
-	Tags        []string `yaml:"tags"`
+	// Updated is the date of the last significant change, if any.
+	Updated       string `yaml:"updated"`
+	updatedParsed time.Time
+	Tags          []string `yaml:"tags"`
 	// Draft will prevent inclusion of the given page in a non-draft build.
 	Draft bool `yaml:"draft"`
 	// Hidden will not show any links to the given page. This works for both
@@ -46,18 +46,59 @@ type ArticleHeaders struct {
 	AuthorEmail string `yaml:"author-email"`
 
 	PodcastAudio string `yaml:"podcast-audio"`
+
+	// Series groups articles that are meant to be read in order.
+	Series seriesHeader `yaml:"series"`
+
+	// TableOfContents overrides the TableOfContents setting of the config
+	// for a single article.
+	TableOfContents *bool `yaml:"toc"`
+
+	// Slug is used as file name of the article instead of the name of the
+	// source file.
+	Slug string `yaml:"slug"`
+
+	// Language overrides the Language of the config for a single page.
+	Language string `yaml:"lang"`
+	// TranslationKey links the variants of an article in different
+	// languages. All variants have to use the same key.
+	TranslationKey string `yaml:"translation-key"`
+}
+
+// articleDateLayouts are the accepted formats for the date headers. Dates
+// without a timezone offset are interpreted in the configured timezone.
+var articleDateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04",
+	time.RFC3339,
 }
 
-func (headers *ArticleHeaders) Parse() error {
+func parseArticleDate(value string, location *time.Location) (time.Time, error) {
+	for _, layout := range articleDateLayouts {
+		if parsed, err := time.ParseInLocation(layout, value, location); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("date '%s' must be formatted as either 'YYYY-MM-DD', 'YYYY-MM-DD HH:MM' or RFC3339", value)
+}
+
+func (headers *ArticleHeaders) Parse(location *time.Location) error {
 	var errs []error
 
 	if headers.Date != "" {
-		dateParsed, err := time.Parse("2006-01-02", headers.Date)
+		dateParsed, err := parseArticleDate(headers.Date, location)
 		if err != nil {
 			errs = append(errs, err)
 		}
 		headers.dateParsed = dateParsed
 	}
+	if headers.Updated != "" {
+		updatedParsed, err := parseArticleDate(headers.Updated, location)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		headers.updatedParsed = updatedParsed
+	}
 
 	return errors.Join(errs...)
 }
@@ -66,14 +107,46 @@ func (headers *ArticleHeaders) Parse() error {
 // occur during live mode, where changes are constantly applied to the running
 // server.
 type Builder struct {
-	templates *template.Template
+	// skeletons must never be executed, as executed templates can't be
+	// cloned anymore. Each build works on its own copy instead, see
+	// loadTheme.
+	skeletons *template.Template
+	// templateHash changes whenever any of the embedded skeletons change.
+	templateHash string
+	// generatorHash changes whenever stasi-blog itself changes.
+	generatorHash string
+}
+
+// BuildOptions are the settings that may differ between builds of the same
+// source directory.
+type BuildOptions struct {
+	MinifyOutput  bool
+	IncludeDrafts bool
+	// Clean ignores the manifest of the previous build, causing all files to
+	// be regenerated.
+	Clean bool
+	// ThemeDir contains files overriding the embedded skeletons. If empty,
+	// the directory "theme" inside of the source directory is used.
+	ThemeDir string
+	// IncludeFuture includes articles dated in the future, which are
+	// otherwise treated as unpublished. IncludeDrafts has the same effect.
+	IncludeFuture bool
+	// MarkScheduled visibly marks articles dated in the future, so they can
+	// be told apart while previewing the blog.
+	MarkScheduled bool
+	// CheckLinks verifies all internal links after the build, failing the
+	// build if any of them are broken.
+	CheckLinks bool
+	// ConfigOverrides override settings of the config, each in the format
+	// "Key=Value". See loadConfig.
+	ConfigOverrides []string
 }
 
 func NewBuilder() (*Builder, error) {
 	builder := &Builder{}
 
 	var err error
-	builder.templates, err = template.New("").
+	builder.skeletons, err = template.New("").
 		Funcs(template.FuncMap{
 			// Both sub and add are used for the paging numbers
 			"sub": func(a, b int) int {
@@ -88,47 +161,82 @@ func NewBuilder() (*Builder, error) {
 		return nil, fmt.Errorf("couldn't parse HTML templates: %w", err)
 	}
 
+	builder.templateHash, err = hashFS(skeletonFS, "skeletons")
+	if err != nil {
+		return nil, fmt.Errorf("couldn't hash skeletons: %w", err)
+	}
+	builder.generatorHash = generatorHash()
+
 	return builder, nil
 }
 
 func (builder *Builder) Build(
 	sourceDir, outputDir, configPath string,
-	minifyOutput, includeDrafts bool,
+	options BuildOptions,
 ) error {
-	if err := cleanup(outputDir); err != nil {
-		return fmt.Errorf("error performing cleanup: %w", err)
+	// Articles dated after this point in time aren't published yet.
+	buildTime := time.Now()
+
+	manifest, err := loadBuildManifest(outputDir)
+	if err != nil {
+		return fmt.Errorf("error loading build manifest: %w", err)
+	}
+	if options.Clean {
+		if err := manifest.discardPrevious(); err != nil {
+			return fmt.Errorf("error deleting previous output: %w", err)
+		}
+	}
+
+	// Without a manifest, we can't know which files we've generated, so we
+	// fall back to deleting everything we might have generated.
+	if !manifest.hasPrevious() {
+		if err := cleanup(outputDir); err != nil {
+			return fmt.Errorf("error performing cleanup: %w", err)
+		}
 	}
 
-	err := createDirectories(
+	err = createDirectories(
 		filepath.Join(outputDir, "media"),
 		filepath.Join(outputDir, "articles"),
 		filepath.Join(outputDir, "pages"),
+		filepath.Join(outputDir, "feeds"),
+		filepath.Join(outputDir, "series"),
 	)
 	if err != nil {
 		return fmt.Errorf("error preparing target folder structure: %w", err)
 	}
 
-	blogConfig := blogConfig{
-		DateFormat:      "2 January 2006",
-		MaxIndexEntries: 10,
-	}
-	if configPath == "" {
-		configPath = filepath.Join(sourceDir, "config.json")
+	themeDir := options.ThemeDir
+	if themeDir == "" {
+		themeDir = filepath.Join(sourceDir, "theme")
 	}
-	configFile, err := os.Open(configPath)
+	theme, err := builder.loadTheme(themeDir)
 	if err != nil {
-		return fmt.Errorf("error loading config '%s': %w", configPath, err)
+		return fmt.Errorf("error loading theme: %w", err)
 	}
+	templates := theme.templates
 
-	if err := json.NewDecoder(configFile).Decode(&blogConfig); err != nil {
-		log.Fatalf("Error decoding config: %s\n", err)
+	if configPath == "" {
+		configPath, err = findConfig(sourceDir)
+		if err != nil {
+			return err
+		}
+	}
+	blogConfig, err := loadConfig(configPath, options.ConfigOverrides)
+	if err != nil {
+		return err
 	}
-	if blogConfig.BasePath != "" {
-		// Making sure there's not too many or too little slashes ;)
-		blogConfig.BasePath = "/" + strings.Trim(blogConfig.BasePath, `/\`)
+	location := time.UTC
+	if blogConfig.TimeZone != "" {
+		location, err = time.LoadLocation(blogConfig.TimeZone)
+		if err != nil {
+			return fmt.Errorf("error loading TimeZone '%s': %w", blogConfig.TimeZone, err)
+		}
 	}
+	mainLanguage := blogConfig.Language
+	blogConfig.localize(mainLanguage, mainLanguage)
 
-	blogConfig.Favicon, err = copyFavicon(sourceDir, outputDir)
+	blogConfig.Favicon, err = copyFavicon(sourceDir, outputDir, manifest)
 	if err != nil {
 		return fmt.Errorf("error copying favicon: %w", err)
 	}
@@ -151,55 +259,101 @@ func (builder *Builder) Build(
 	}
 
 	// We collect these to display them on the page header.
-	customPages := make([]*customPageEntry, len(customPageFiles))
-
-	for index, customPage := range customPageFiles {
-		customPageSkeletonClone, err := builder.templates.Lookup("page").Clone()
-		if err != nil {
-			return fmt.Errorf("couldn't clone 'page' template: %w", err)
+	customPages := make([]*customPageEntry, 0, len(customPageFiles))
+	// All pages that should be found by search engines.
+	var sitemapEntries []sitemapEntry
+	// pageSources maps output files to their source, as for example
+	// "about.md" and "about.html" would both end up as "about.html".
+	pageSources := make(map[string]string, len(customPageFiles))
+
+	for _, customPage := range customPageFiles {
+		if !isPageFile(customPage.Name()) {
+			continue
 		}
 
 		sourcePath := filepath.Join(sourceDir, "pages", customPage.Name())
-		headers, rawCustomPage, err := parsePage(sourcePath)
+		headers, rawCustomPage, err := parsePage(sourcePath, location)
 		if err != nil {
 			return fmt.Errorf("error parsing page '%s': %w", customPage.Name(), err)
 		}
 
-		if !includeDrafts && headers.Draft {
+		if !options.IncludeDrafts && headers.Draft {
 			if *verbose {
 				fmt.Printf("Skipping page draft '%s'\n", customPage.Name())
 			}
 			continue
 		}
 
-		rawCustomPage, _, err = transformPageForWeb(rawCustomPage)
-		if err != nil {
-			return fmt.Errorf("error transforming page: %w", err)
-		}
-
-		customPageTemplate, err := customPageSkeletonClone.Parse(`{{define "content"}}` + string(rawCustomPage) + `{{end}}`)
-		if err != nil {
-			return fmt.Errorf("couldn't parse custom page '%s': %w", customPage.Name(), err)
-		}
-
 		data := &customPageData{
-			blogConfig:  blogConfig,
-			CustomPages: customPages,
+			blogConfig: blogConfig,
+		}
+		if headers.Language != "" {
+			pageLanguage, err := parseLanguage(headers.Language)
+			if err != nil {
+				return fmt.Errorf("error parsing page '%s': %w", customPage.Name(), err)
+			}
+			data.localize(pageLanguage, mainLanguage)
 		}
 		data.Hidden = headers.Hidden
 		data.Title = headers.Title
-		file := path.Join("pages", customPage.Name())
-		customPages[index] = &customPageEntry{
-			Title:    headers.Title,
-			Hidden:   headers.Hidden,
-			File:     file,
-			data:     data,
-			template: customPageTemplate,
+		file := path.Join("pages", outputFileName(customPage.Name()))
+		if otherSource, exists := pageSources[file]; exists {
+			return fmt.Errorf("pages '%s' and '%s' would both be written to '%s', rename one of them", otherSource, customPage.Name(), file)
+		}
+		pageSources[file] = customPage.Name()
+		customPages = append(customPages, &customPageEntry{
+			Title:   headers.Title,
+			Hidden:  headers.Hidden,
+			File:    file,
+			data:    data,
+			content: rawCustomPage,
+		})
+
+		if !headers.Hidden && !headers.Draft {
+			sitemapEntries = append(sitemapEntries, sitemapEntry{
+				File:         file,
+				LastModified: headers.updatedParsed,
+			})
 		}
 	}
 
+	// Every page contains the header, which lists all custom pages. Therefore
+	// any change to the config, templates or custom pages affects all pages.
+	if *verbose {
+		log.Println("Copying media directory.")
+	}
+	// The media directory is handled early, as pages need the dimensions of
+	// the images and have to be regenerated if any of them change.
+	images, mediaHash, err := copyMediaDirectory(sourceDir, outputDir, blogConfig, manifest)
+	if err != nil {
+		return fmt.Errorf("couldn't copy media directory: %w", err)
+	}
+
+	sharedHash := hashInputs(builder.generatorHash, theme.hash, blogConfig, options.MinifyOutput, customPages, mediaHash)
+
 	for _, page := range customPages {
-		if err := writeTemplateToFile(page.template, page.data, outputDir, page.File, minifyOutput); err != nil {
+		page.data.CustomPages = customPages
+		if manifest.upToDate(page.File, hashInputs(sharedHash, page.data, page.content)) {
+			continue
+		}
+
+		customPageSkeletonClone, err := templates.Lookup("page").Clone()
+		if err != nil {
+			return fmt.Errorf("couldn't clone 'page' template: %w", err)
+		}
+
+		transformedContent, meta, err := transformPageForWeb(page.content, images)
+		if err != nil {
+			return fmt.Errorf("error transforming page: %w", err)
+		}
+		page.data.HighlightedCode = meta.HighlightedCode
+
+		customPageTemplate, err := customPageSkeletonClone.Parse(`{{define "content"}}` + string(transformedContent) + `{{end}}`)
+		if err != nil {
+			return fmt.Errorf("couldn't parse custom page '%s': %w", page.File, err)
+		}
+
+		if err := writeTemplateToFile(customPageTemplate, page.data, outputDir, page.File, options.MinifyOutput); err != nil {
 			return fmt.Errorf("error writing custom page: %w", err)
 		}
 	}
@@ -213,50 +367,53 @@ func (builder *Builder) Build(
 		log.Println("Indexing and writing articles ...")
 	}
 	indexedArticles := make([]*indexedArticle, 0, len(articles))
+	// Articles are only written once all of them have been parsed, as they
+	// may link to each other, for example when they're part of a series.
+	pendingArticles := make([]*pendingArticle, 0, len(articles))
+	var seriesMembers []*seriesMember
+	// articleSources maps output files to their source, as different source
+	// files might end up with the same output file via the slug header.
+	articleSources := make(map[string]string, len(articles))
 	for _, article := range articles {
-		//Other files are ignored. For example I use this to create
-		//.html-draft files which are posts that I don't want to publish
-		//yet, but still have in the blog source directory.
-		if !strings.HasSuffix(article.Name(), ".html") {
+		if !isPageFile(article.Name()) {
 			continue
 		}
 
-		newArticleSkeleton, err := builder.templates.Lookup("article").Clone()
-		if err != nil {
-			return fmt.Errorf("couldn't clone article template: %w", err)
-		}
-
 		sourcePath := filepath.Join(sourceDir, "articles", article.Name())
-		headers, rawContent, err := parsePage(sourcePath)
+		headers, rawContent, err := parsePage(sourcePath, location)
 		if err != nil {
 			return fmt.Errorf("error parsing article '%s': %w", article.Name(), err)
 		}
 
-		if !includeDrafts && headers.Draft {
+		if !options.IncludeDrafts && headers.Draft {
 			if *verbose {
 				fmt.Printf("Skipping article draft '%s'\n", article.Name())
 			}
 			continue
 		}
 
-		transformedContent, meta, err := transformPageForWeb(rawContent)
-		if err != nil {
-			return fmt.Errorf("error transforming article: %w", err)
+		scheduled := headers.dateParsed.After(buildTime)
+		if scheduled && !options.IncludeFuture && !options.IncludeDrafts {
+			if *verbose {
+				fmt.Printf("Skipping article '%s' scheduled for %s\n", article.Name(), headers.Date)
+			}
+			continue
 		}
 
-		specificArticleTemplate, err := newArticleSkeleton.Parse(
-			`{{define "content"}}` + string(transformedContent) + `{{end}}`,
-		)
-		if err != nil {
-			return fmt.Errorf("couldn't parse article '%s': %w", article.Name(), err)
-		}
 		articleData := &articlePageData{
 			blogConfig:  blogConfig,
 			CustomPages: customPages,
-			Asciicasts:  meta.Asciicasts,
+		}
+		if headers.Language != "" {
+			articleLanguage, err := parseLanguage(headers.Language)
+			if err != nil {
+				return fmt.Errorf("error parsing article '%s': %w", article.Name(), err)
+			}
+			articleData.localize(articleLanguage, mainLanguage)
 		}
 
 		articleData.Hidden = headers.Hidden
+		articleData.Scheduled = scheduled && options.MarkScheduled
 		articleData.Title = headers.Title
 		articleData.Description = headers.Description
 		for tagIndex, tag := range headers.Tags {
@@ -268,32 +425,59 @@ func (builder *Builder) Build(
 		articleData.Tags = headers.Tags
 
 		articleData.RFC3339Time = headers.dateParsed.Format(time.RFC3339)
-		articleData.HumanTime = headers.dateParsed.Format(blogConfig.DateFormat)
+		if !headers.updatedParsed.IsZero() {
+			articleData.RFC3339Updated = headers.updatedParsed.Format(time.RFC3339)
+		}
+		articleData.HumanTime = formatDate(headers.dateParsed, blogConfig.DateFormat, articleData.Strings)
 		if headers.PodcastAudio != "" {
 			if strings.HasPrefix(strings.TrimPrefix(headers.PodcastAudio, "/"), "media") {
 				articleData.PodcastAudio = path.Join(blogConfig.BasePath, headers.PodcastAudio)
 			}
 		}
-		articleTargetPath := filepath.Join("articles", article.Name())
+		articleFile := outputFileName(article.Name())
+		if headers.Slug != "" {
+			articleFile = slugify(headers.Slug, blogConfig.Slugs) + ".html"
+		}
+		if otherSource, exists := articleSources[articleFile]; exists {
+			return fmt.Errorf("articles '%s' and '%s' would both be written to '%s', use the slug header to rename one of them", otherSource, article.Name(), articleFile)
+		}
+		articleSources[articleFile] = article.Name()
+		articleTargetPath := filepath.Join("articles", articleFile)
 
+		var newIndexedArticle *indexedArticle
 		if !articleData.Hidden {
-			feedContent, err := transformPageForRSS(rawContent)
+			feedContent, err := transformPageForRSS(rawContent, blogConfig.CodeStyle)
 			if err != nil {
 				return fmt.Errorf("error transforming content for feed: %w", err)
 			}
 
-			newIndexedArticle := &indexedArticle{
-				blogConfig:   blogConfig,
-				podcastAudio: articleData.PodcastAudio,
-				Title:        headers.Title,
-				File:         path.Join("articles", article.Name()),
-				RFC3339Time:  headers.dateParsed,
-				HumanTime:    articleData.HumanTime,
-				FeedContent:  string(feedContent),
-				Tags:         headers.Tags,
-				AuthorName:   headers.Author,
-				AuthorEmail:  headers.AuthorEmail,
+			newIndexedArticle = &indexedArticle{
+				blogConfig:  articleData.blogConfig,
+				Title:       headers.Title,
+				File:        path.Join("articles", articleFile),
+				RFC3339Time: headers.dateParsed,
+				Updated:     headers.updatedParsed,
+				HumanTime:   articleData.HumanTime,
+				FeedContent: string(feedContent),
+				Tags:        headers.Tags,
+				AuthorName:  headers.Author,
+				AuthorEmail: headers.AuthorEmail,
+				Scheduled:   articleData.Scheduled,
+
+				translationKey: headers.TranslationKey,
+			}
+			if articleData.PodcastAudio != "" {
+				newIndexedArticle.podcastAudio = headers.PodcastAudio
 			}
+			if blogConfig.Search {
+				newIndexedArticle.searchText, err = extractText(rawContent)
+				if err != nil {
+					return fmt.Errorf("error extracting text for search index: %w", err)
+				}
+			}
+			// Fix page metadata to include the article description instead
+			// of the blog description.
+			newIndexedArticle.Description = headers.Description
 			// Fix page metadata to include correct name instead of main author.
 			if newIndexedArticle.AuthorName != "" {
 				newIndexedArticle.Author = newIndexedArticle.AuthorName
@@ -301,169 +485,491 @@ func (builder *Builder) Build(
 			}
 
 			indexedArticles = append(indexedArticles, newIndexedArticle)
+			if headers.Series.Name != "" {
+				seriesMembers = append(seriesMembers, &seriesMember{
+					name:    headers.Series.Name,
+					order:   headers.Series.Order,
+					article: newIndexedArticle,
+					data:    articleData,
+				})
+			}
+
+			if !headers.Draft && !scheduled {
+				lastModified := headers.updatedParsed
+				if lastModified.IsZero() {
+					lastModified = headers.dateParsed
+				}
+				sitemapEntries = append(sitemapEntries, sitemapEntry{
+					File:         newIndexedArticle.File,
+					LastModified: lastModified,
+				})
+			}
 		}
 
-		if err := writeTemplateToFile(specificArticleTemplate, articleData, outputDir, articleTargetPath, minifyOutput); err != nil {
-			return fmt.Errorf("error writing article: %w", err)
+		showTableOfContents := blogConfig.TableOfContents
+		if headers.TableOfContents != nil {
+			showTableOfContents = *headers.TableOfContents
 		}
+
+		pendingArticles = append(pendingArticles, &pendingArticle{
+			indexed:             newIndexedArticle,
+			name:                article.Name(),
+			targetPath:          articleTargetPath,
+			rawContent:          rawContent,
+			data:                articleData,
+			showTableOfContents: showTableOfContents,
+			translationKey:      headers.TranslationKey,
+		})
 	}
 
 	// Sort articles to make sure the RSS feed and index have the right ordering.
+	// Articles published at the same time are sorted by file name, so that
+	// the order doesn't change between builds.
 	sort.Slice(indexedArticles, func(a, b int) bool {
 		articleA := indexedArticles[a]
 		articleB := indexedArticles[b]
+		if articleA.RFC3339Time.Equal(articleB.RFC3339Time) {
+			return articleA.File < articleB.File
+		}
 		return articleB.RFC3339Time.Before(articleA.RFC3339Time)
 	})
 
-	// Collect tags from all articles for listing them in the index files.
-	var tags []string
-	for _, article := range indexedArticles {
-	NEW_TAG_LOOP:
-		for _, tag := range article.Tags {
-			for _, existingTag := range tags {
-				if existingTag == tag {
-					continue NEW_TAG_LOOP
-				}
+	allSeries, err := groupSeries(seriesMembers, blogConfig.Slugs)
+	if err != nil {
+		return err
+	}
+
+	if err := linkTranslations(pendingArticles, blogConfig); err != nil {
+		return err
+	}
+
+	// Each language has its own index pages, feeds, tags and archive.
+	// Articles only link to articles of their own language as well.
+	languages := groupLanguages(mainLanguage, customPages, pendingArticles, indexedArticles)
+	languageArticles := make(map[string][]*indexedArticle, len(languages))
+	articlePositions := make(map[*indexedArticle]int, len(indexedArticles))
+	for _, group := range languages {
+		languageArticles[group.language] = group.articles
+		for position, article := range group.articles {
+			articlePositions[article] = position
+		}
+	}
+
+	for _, article := range pendingArticles {
+		if article.indexed != nil {
+			// Articles are sorted newest first.
+			neighbours := languageArticles[article.data.Language]
+			position := articlePositions[article.indexed]
+			if position+1 < len(neighbours) {
+				article.data.PreviousArticle = newArticleLink(neighbours[position+1])
 			}
+			if position > 0 {
+				article.data.NextArticle = newArticleLink(neighbours[position-1])
+			}
+			article.data.RelatedArticles = relatedArticles(article.indexed, neighbours, blogConfig.MaxRelatedArticles)
+		}
+
+		if manifest.upToDate(article.targetPath, hashInputs(sharedHash, article.data, article.rawContent, article.showTableOfContents)) {
+			continue
+		}
+
+		newArticleSkeleton, err := templates.Lookup("article").Clone()
+		if err != nil {
+			return fmt.Errorf("couldn't clone article template: %w", err)
+		}
+
+		transformedContent, meta, err := transformPageForWeb(article.rawContent, images)
+		if err != nil {
+			return fmt.Errorf("error transforming article: %w", err)
+		}
+		article.data.Asciicasts = meta.Asciicasts
+		article.data.HighlightedCode = meta.HighlightedCode
+		if article.showTableOfContents {
+			article.data.TableOfContents = buildTableOfContents(meta.Headings)
+		}
+
+		specificArticleTemplate, err := newArticleSkeleton.Parse(
+			`{{define "content"}}` + string(transformedContent) + `{{end}}`,
+		)
+		if err != nil {
+			return fmt.Errorf("couldn't parse article '%s': %w", article.name, err)
+		}
 
-			tags = append(tags, tag)
+		if err := writeTemplateToFile(specificArticleTemplate, article.data, outputDir, article.targetPath, options.MinifyOutput); err != nil {
+			return fmt.Errorf("error writing article: %w", err)
 		}
 	}
-	slices.Sort(tags)
 
-	if *verbose {
-		log.Println("Writing main index files.")
+	if err := writeSeriesPages(theme, allSeries, seriesPageData{
+		blogConfig:  blogConfig,
+		CustomPages: customPages,
+	}, outputDir, options.MinifyOutput, manifest, sharedHash); err != nil {
+		return err
+	}
+	for _, series := range allSeries {
+		sitemapEntries = append(sitemapEntries, sitemapEntry{File: series.File})
 	}
-	indexTemplate := builder.templates.Lookup("index")
-	writeIndexFiles(indexTemplate, indexedArticles, customPages, blogConfig,
-		tags, "", "index.html", "index-%d.html", outputDir, minifyOutput)
+
+	// indexFileOwners maps generated index files to the tag they belong to,
+	// as the pages of one tag might clash with another tag, for example the
+	// second page of the tag "go" and the tag "go-2".
+	indexFileOwners := make(map[string]string)
 
 	if *verbose {
-		log.Println("Writing tagged index files.")
-	}
-	// Special Index-Files with tag-filters
-	for _, tag := range tags {
-		var tagFilteredArticles []*indexedArticle
-	ARTICLE_LOOP:
-		for _, article := range indexedArticles {
-			for _, articleTag := range article.Tags {
-				if articleTag == tag {
-					tagFilteredArticles = append(tagFilteredArticles, article)
-					continue ARTICLE_LOOP
+		log.Println("Writing index files, feeds, tags and archive.")
+	}
+	// The index pages, feeds, tags and archive of the main language are
+	// written to the root of the output directory, so blogs using only one
+	// language aren't affected.
+	var languageAlternates []languageLink
+	if len(languages) > 1 {
+		for _, group := range languages {
+			link, err := newLanguageLink(blogConfig, group.language, languageDir(group.language, mainLanguage)+"index.html")
+			if err != nil {
+				return err
+			}
+			languageAlternates = append(languageAlternates, link)
+		}
+	}
+	indexTemplate := templates.Lookup("index")
+	for _, group := range languages {
+		languageConfig := blogConfig
+		languageConfig.localize(group.language, mainLanguage)
+		if languageConfig.LanguageDir != "" {
+			if err := createDirectories(filepath.Join(outputDir, languageConfig.LanguageDir, "feeds")); err != nil {
+				return fmt.Errorf("error preparing directory for language '%s': %w", group.language, err)
+			}
+		}
+
+		// Tags only list the articles of the language they're used in.
+		tags, err := collectTags(group.articles, blogConfig.Tags, blogConfig.Slugs, blogConfig.MaxIndexEntries)
+		if err != nil {
+			return err
+		}
+
+		indexFiles, err := writeIndexFiles(indexTemplate, group.articles, indexData{
+			blogConfig:  languageConfig,
+			Tags:        tags,
+			CustomPages: customPages,
+			Alternates:  languageAlternates,
+		}, languageConfig.LanguageDir+"index.html", languageConfig.LanguageDir+"index-%d.html", outputDir, options.MinifyOutput,
+			manifest, sharedHash)
+		if err != nil {
+			return fmt.Errorf("error writing index files for language '%s': %w", group.language, err)
+		}
+		for _, indexFile := range indexFiles {
+			indexFileOwners[indexFile] = ""
+			sitemapEntries = append(sitemapEntries, sitemapEntry{File: indexFile})
+		}
+
+		feed, err := createFeed(sourceDir, group.articles, languageConfig)
+		if err != nil {
+			return fmt.Errorf("error creating feed for language '%s': %w", group.language, err)
+		}
+		if err := writeFeeds(outputDir, languageConfig.LanguageDir, feed, languageConfig.Language, manifest); err != nil {
+			return fmt.Errorf("error writing feeds for language '%s': %w", group.language, err)
+		}
+
+		// Special Index-Files with tag-filters
+		for _, tagInfo := range tags {
+			tag := tagInfo.Tag
+			var tagFilteredArticles []*indexedArticle
+		ARTICLE_LOOP:
+			for _, article := range group.articles {
+				for _, articleTag := range article.Tags {
+					if articleTag == tag {
+						tagFilteredArticles = append(tagFilteredArticles, article)
+						continue ARTICLE_LOOP
+					}
 				}
 			}
+
+			tagFeedFile := languageConfig.LanguageDir + path.Join("feeds", tagInfo.Slug+".xml")
+			tagIndexData := indexData{
+				blogConfig:    languageConfig,
+				Tags:          tags,
+				FilterTag:     tag,
+				FilterTagInfo: tagInfo,
+				FeedFile:      tagFeedFile,
+				CustomPages:   customPages,
+			}
+			if tagInfo.Description != "" {
+				tagIndexData.Description = tagInfo.Description
+			}
+			tagIndexFile := languageConfig.LanguageDir + "index-" + tagInfo.Slug
+			indexFiles, err := writeIndexFiles(indexTemplate, tagFilteredArticles, tagIndexData, tagIndexFile+".html", tagIndexFile+"-%d.html", outputDir, options.MinifyOutput,
+				manifest, sharedHash)
+			if err != nil {
+				return fmt.Errorf("error writing index files for tag '%s': %w", tag, err)
+			}
+			for _, indexFile := range indexFiles {
+				if owner, exists := indexFileOwners[indexFile]; exists {
+					if owner == "" {
+						owner = "the main index"
+					} else {
+						owner = fmt.Sprintf("tag '%s'", owner)
+					}
+					return fmt.Errorf("tag '%s' and %s both use the file '%s', set a different Slug for the tag in the Tags config", tag, owner, indexFile)
+				}
+				indexFileOwners[indexFile] = tag
+				sitemapEntries = append(sitemapEntries, sitemapEntry{File: indexFile})
+			}
+
+			tagFeed, err := createFeed(sourceDir, tagFilteredArticles, languageConfig)
+			if err != nil {
+				return fmt.Errorf("error creating feed for tag '%s': %w", tag, err)
+			}
+			tagFeed.Title = fmt.Sprintf("%s: %s", tagFeed.Title, tagInfo.Name)
+			if tagInfo.Description != "" {
+				tagFeed.Description = tagInfo.Description
+			}
+			rssData, err := tagFeed.ToRss()
+			if err != nil {
+				return fmt.Errorf("error generating RSS feed for tag '%s': %w", tag, err)
+			}
+			if err := writeFileIfChanged(outputDir, tagFeedFile, rssData, manifest); err != nil {
+				return fmt.Errorf("error writing feed for tag '%s': %w", tag, err)
+			}
 		}
 
-		writeIndexFiles(indexTemplate, tagFilteredArticles, customPages, blogConfig,
-			tags, tag, "index-"+tag+".html", "index-"+tag+"-%d.html", outputDir, minifyOutput)
-	}
+		if err := writeTagsPage(theme, tagsPageData{
+			blogConfig:  languageConfig,
+			CustomPages: customPages,
+			Tags:        tags,
+		}, outputDir, options.MinifyOutput, manifest, sharedHash); err != nil {
+			return err
+		}
+		sitemapEntries = append(sitemapEntries, sitemapEntry{File: languageConfig.LanguageDir + "tags.html"})
 
-	if *verbose {
-		log.Println("Writing RSS feed.")
-	}
-	if err := writeRSSFeed(sourceDir, outputDir, indexedArticles, blogConfig); err != nil {
-		return fmt.Errorf("error writing rss feed: %w", err)
+		archiveFiles, err := writeArchive(theme, group.articles, archivePageData{
+			blogConfig:  languageConfig,
+			CustomPages: customPages,
+		}, outputDir, options.MinifyOutput, manifest, sharedHash)
+		if err != nil {
+			return err
+		}
+		for _, archiveFile := range archiveFiles {
+			sitemapEntries = append(sitemapEntries, sitemapEntry{File: archiveFile})
+		}
 	}
 
-	baseCSSFile, err := skeletonFS.Open("skeletons/base.css")
-	if err != nil {
-		return fmt.Errorf("couldn't read base.css: %w", err)
+	if blogConfig.Search {
+		if *verbose {
+			log.Println("Writing search page and index.")
+		}
+		searchData := &customPageData{
+			blogConfig:  blogConfig,
+			CustomPages: customPages,
+		}
+		if err := writeSearch(theme, indexedArticles, searchData, outputDir,
+			options.MinifyOutput, manifest, sharedHash); err != nil {
+			return fmt.Errorf("error writing search: %w", err)
+		}
 	}
-	asciinemaJSFile, err := skeletonFS.Open("skeletons/asciinema-player.min.js")
-	if err != nil {
-		return fmt.Errorf("couldn't read asciinema-player.min.js`: %w", err)
+
+	if blogConfig.URL != "" {
+		if *verbose {
+			log.Println("Writing sitemap.xml and robots.txt.")
+		}
+		if err := writeSitemap(outputDir, sitemapEntries, blogConfig, manifest); err != nil {
+			return fmt.Errorf("error writing sitemap: %w", err)
+		}
+	} else if *verbose {
+		log.Println("Warning: Skipping sitemap.xml and robots.txt, as no URL has been configured.")
 	}
-	asciinemaCSSFile, err := skeletonFS.Open("skeletons/asciinema-player.css")
-	if err != nil {
-		return fmt.Errorf("couldn't read asciinema-player.css: %w", err)
+
+	if !manifest.upToDate("base.css", hashInputs(theme.hash, options.MinifyOutput)) {
+		baseCSSFile, err := theme.openAsset("base.css")
+		if err != nil {
+			return fmt.Errorf("couldn't read base.css: %w", err)
+		}
+		defer baseCSSFile.Close()
+
+		if options.MinifyOutput {
+			if *verbose {
+				log.Println("Copying and minifying base.css.")
+			}
+			baseCSSOutput, err := createFile(filepath.Join(outputDir, "base.css"))
+			if err != nil {
+				return err
+			}
+			defer baseCSSOutput.Close()
+
+			if err := minifier.Minify("text/css", baseCSSOutput, baseCSSFile); err != nil {
+				return fmt.Errorf("couldn't minify base.css: %w", err)
+			}
+		} else {
+			if *verbose {
+				log.Println("Copying base.css ...")
+			}
+			if err := copyDataIntoFile(baseCSSFile, filepath.Join(outputDir, "base.css")); err != nil {
+				return err
+			}
+		}
 	}
 
-	if minifyOutput {
+	highlightHash := hashInputs(theme.hash, blogConfig.CodeStyle, blogConfig.CodeStyleDark, options.MinifyOutput)
+	if !manifest.upToDate("highlight.css", highlightHash) {
 		if *verbose {
-			log.Println("Copying and minifying base.css.")
+			log.Println("Writing highlight.css ...")
 		}
-		baseCSSOutput, err := createFile(filepath.Join(outputDir, "base.css"))
-		if err != nil {
-			return err
+		if err := writeHighlightCSSFile(theme, outputDir, blogConfig, options.MinifyOutput); err != nil {
+			return fmt.Errorf("couldn't write highlight.css: %w", err)
 		}
-		defer baseCSSOutput.Close()
+	}
 
-		if err := minifier.Minify("text/css", baseCSSOutput, baseCSSFile); err != nil {
-			return fmt.Errorf("couldn't minify base.css: %w", err)
+	for _, asciinemaFile := range []string{"asciinema-player.min.js", "asciinema-player.css"} {
+		if manifest.upToDate(asciinemaFile, theme.hash) {
+			continue
 		}
-	} else {
+
 		if *verbose {
-			log.Println("Copying base.css ...")
+			log.Printf("Copying %s ...\n", asciinemaFile)
+		}
+		source, err := theme.openAsset(asciinemaFile)
+		if err != nil {
+			return fmt.Errorf("couldn't read %s: %w", asciinemaFile, err)
 		}
-		if err := copyDataIntoFile(baseCSSFile, filepath.Join(outputDir, "base.css")); err != nil {
+
+		err = copyDataIntoFile(source, filepath.Join(outputDir, asciinemaFile))
+		source.Close()
+		if err != nil {
 			return err
 		}
 	}
 
-	if *verbose {
-		log.Println("Copying media directory.")
-	}
+	if !manifest.upToDate("404.html", sharedHash) {
+		if *verbose {
+			log.Println("Writing 404.html")
+		}
 
-	if *verbose {
-		log.Println("Copying asciinema-player.min.js ...")
-	}
-	if err := copyDataIntoFile(asciinemaJSFile, filepath.Join(outputDir, "asciinema-player.min.js")); err != nil {
-		return err
+		if err := writeTemplateToFile(templates.Lookup("404"), &customPageData{
+			blogConfig:  blogConfig,
+			CustomPages: customPages,
+		}, outputDir, "404.html", options.MinifyOutput); err != nil {
+			return err
+		}
 	}
-	if *verbose {
-		log.Println("Copying asciinema-player.css ...")
+
+	if err := manifest.removeStale(); err != nil {
+		return fmt.Errorf("error removing stale files: %w", err)
 	}
-	if err := copyDataIntoFile(asciinemaCSSFile, filepath.Join(outputDir, "asciinema-player.css")); err != nil {
+	if err := manifest.save(); err != nil {
 		return err
 	}
 
-	if err := copy.Copy(filepath.Join(sourceDir, "media"), filepath.Join(outputDir, "media")); err != nil {
-		return fmt.Errorf("couldn't copy media directory: %w", err)
+	if options.CheckLinks {
+		if *verbose {
+			log.Println("Checking links.")
+		}
+		diagnostics, err := checkLinks(outputDir, blogConfig.BasePath, blogConfig.URL)
+		if err != nil {
+			return fmt.Errorf("error checking links: %w", err)
+		}
+		return reportBrokenLinks(diagnostics)
 	}
+	return nil
+}
 
-	if *verbose {
-		log.Println("Writing 404.html")
-	}
+// copyMediaDirectory copies all files from the media directory, that have
+// changed since the last build. Additionally, resized copies of images are
+// generated if configured. The returned hash changes whenever any of the
+// files in the media directory change.
+func copyMediaDirectory(
+	sourceDir, outputDir string,
+	loadedPageConfig blogConfig,
+	manifest *buildManifest,
+) (*imageSet, string, error) {
+	images := newImageSet(loadedPageConfig)
+	hashes := make(map[string]string)
+	err := filepath.WalkDir(
+		filepath.Join(sourceDir, "media"),
+		func(sourcePath string, dirEntry fs.DirEntry, err error) error {
+			if err != nil || dirEntry.IsDir() {
+				return err
+			}
 
-	return writeTemplateToFile(builder.templates.Lookup("404"), &customPageData{
-		blogConfig:  blogConfig,
-		CustomPages: customPages,
-	}, outputDir, "404.html", minifyOutput)
-}
+			relativePath, err := filepath.Rel(sourceDir, sourcePath)
+			if err != nil {
+				return err
+			}
+			relativePath = filepath.ToSlash(relativePath)
 
-func copyFavicon(sourceDir, outputDir string) (string, error) {
-	// .ico is preferred, as it has multi resolution support.
-	err := copyFileByPath(
-		filepath.Join(sourceDir, "favicon.ico"),
-		filepath.Join(outputDir, "favicon.ico"))
-	if err == nil {
-		return "favicon.ico", nil
-	}
+			hash, err := hashFile(sourcePath)
+			if err != nil {
+				return err
+			}
+			hashes[relativePath] = hash
+
+			if isImageFile(relativePath) {
+				image, err := readMediaImage(sourcePath)
+				if err != nil {
+					// The image is still copied, it simply won't get any
+					// dimensions or resized copies.
+					if *verbose {
+						log.Printf("Warning: couldn't read dimensions of image '%s': %s\n", relativePath, err)
+					}
+				} else {
+					images.images[relativePath] = image
+				}
 
-	// If we encounter any error, aside from non-existence, we early
-	// exit, as trying the other format doesn't make sense.
-	if !os.IsNotExist(err) {
-		return "", fmt.Errorf("error copying favicon.ico: %w", err)
-	}
+				if image != nil && isResizableImage(relativePath) {
+					err := processMediaImage(image, sourcePath, relativePath, hash, outputDir, loadedPageConfig, manifest)
+					if err != nil {
+						return err
+					}
+				}
+			}
 
-	// Doesn't exist, fallthrough to png.
+			if manifest.upToDate(relativePath, hash) {
+				return nil
+			}
 
-	err = copyFileByPath(
-		filepath.Join(sourceDir, "favicon.png"),
-		filepath.Join(outputDir, "favicon.png"))
-	if err == nil {
-		return "favicon.png", nil
+			targetPath := filepath.Join(outputDir, filepath.FromSlash(relativePath))
+			if err := createDirectories(filepath.Dir(targetPath)); err != nil {
+				return err
+			}
+			return copyFileByPath(sourcePath, targetPath)
+		})
+	if err != nil {
+		return nil, "", err
 	}
 
-	if !os.IsNotExist(err) {
-		return "", fmt.Errorf("error copying favicon.png: %w", err)
+	return images, hashInputs(hashes), nil
+}
+
+func copyFavicon(sourceDir, outputDir string, manifest *buildManifest) (string, error) {
+	// .ico is preferred, as it has multi resolution support.
+	for _, favicon := range []string{"favicon.ico", "favicon.png"} {
+		hash, err := hashFile(filepath.Join(sourceDir, favicon))
+		if err != nil {
+			// If we encounter any error, aside from non-existence, we early
+			// exit, as trying the other format doesn't make sense.
+			if !os.IsNotExist(err) {
+				return "", fmt.Errorf("error reading %s: %w", favicon, err)
+			}
+
+			// Doesn't exist, fallthrough to next format.
+			continue
+		}
+
+		if !manifest.upToDate(favicon, hash) {
+			err := copyFileByPath(
+				filepath.Join(sourceDir, favicon),
+				filepath.Join(outputDir, favicon))
+			if err != nil {
+				return "", fmt.Errorf("error copying %s: %w", favicon, err)
+			}
+		}
+		return favicon, nil
 	}
 
 	return "", nil
 }
 
-// parsePage can parse both articles and custom pages.
-func parsePage(pagePath string) (ArticleHeaders, []byte, error) {
+// parsePage can parse both articles and custom pages. Markdown content is
+// rendered to HTML, so the result can always be treated as HTML.
+func parsePage(pagePath string, location *time.Location) (ArticleHeaders, []byte, error) {
 	var headers ArticleHeaders
 	pageFile, err := os.Open(pagePath)
 	if err != nil {
@@ -490,24 +996,73 @@ func parsePage(pagePath string) (ArticleHeaders, []byte, error) {
 	if err := yaml.Unmarshal(headerAndContent[0], &headers); err != nil {
 		return headers, nil, fmt.Errorf("error reading headers: %w", err)
 	}
-	if err := headers.Parse(); err != nil {
+	if err := headers.Parse(location); err != nil {
 		return headers, nil, fmt.Errorf("error parsing headers: %w", err)
 	}
-	return headers, headerAndContent[1], nil
+
+	content := headerAndContent[1]
+	if isMarkdownFile(pagePath) {
+		content, err = renderMarkdown(content)
+		if err != nil {
+			return headers, nil, fmt.Errorf("error rendering markdown: %w", err)
+		}
+	}
+	return headers, content, nil
 }
 
-func transformPageForRSS(post []byte) ([]byte, error) {
+// transformPageForRSS transforms raw HTML into HTML suitable for feeds.
+// Since feed readers don't load our stylesheets, code is highlighted using
+// inline styles.
+func transformPageForRSS(post []byte, codeStyle string) ([]byte, error) {
 	// FIXME What to do with asciicasts? Convert to links?
-	return post, nil
+	reader := bytes.NewReader(post)
+	writer := bytes.NewBuffer(make([]byte, 0, len(post)+1048))
+	tokenizer := html.NewTokenizer(reader)
+	handleErr := func(err error) ([]byte, error) {
+		if errors.Is(err, io.EOF) {
+			return writer.Bytes(), nil
+		}
+		return nil, err
+	}
+
+	inlineStyle := styles.Get(codeStyle)
+	for {
+		tokenType := tokenizer.Next()
+		token := tokenizer.Token()
+		switch tokenType {
+		case html.ErrorToken:
+			return handleErr(tokenizer.Err())
+		case html.StartTagToken:
+			switch token.Data {
+			case "script":
+				writer.WriteString(token.String())
+				tokenizer.Next()
+				token := tokenizer.Token()
+				writer.WriteString(token.Data)
+				continue
+			case "pre":
+				if _, err := transformCodeBlock(tokenizer, token, writer, inlineStyle); err != nil {
+					return handleErr(err)
+				}
+				continue
+			}
+		}
+
+		writer.WriteString(token.String())
+	}
 }
 
 type transformMeta struct {
 	Asciicasts []asciicastMeta
+	// HighlightedCode indicates that highlight.css is required.
+	HighlightedCode bool
+	// Headings contains all headings in the order of their appearance.
+	Headings []heading
 }
 
 // transformPageForWeb transforms raw HTML into user presentable HTML for the
 // webpage. This is not intended for the RSS feed.
-func transformPageForWeb(post []byte) ([]byte, transformMeta, error) {
+func transformPageForWeb(post []byte, images *imageSet) ([]byte, transformMeta, error) {
 	var meta transformMeta
 
 	reader := bytes.NewReader(post)
@@ -535,12 +1090,23 @@ func transformPageForWeb(post []byte) ([]byte, transformMeta, error) {
 				writer.WriteString(token.Data)
 				continue
 			case "h2", "h3", "h4", "h5", "h6":
-				if err := transformHeading(tokenizer, token, writer); err != nil {
+				heading, err := transformHeading(tokenizer, token, writer)
+				if err != nil {
 					return handleErr(err)
 				}
+				if heading.Id != "" {
+					meta.Headings = append(meta.Headings, heading)
+				}
 				continue
 			case "img":
-				if err := transformImage(token, writer); err != nil {
+				if err := transformImage(token, writer, images); err != nil {
+					return handleErr(err)
+				}
+				continue
+			case "pre":
+				highlighted, err := transformCodeBlock(tokenizer, token, writer, nil)
+				meta.HighlightedCode = meta.HighlightedCode || highlighted
+				if err != nil {
 					return handleErr(err)
 				}
 				continue
@@ -559,7 +1125,7 @@ func transformPageForWeb(post []byte) ([]byte, transformMeta, error) {
 				}
 				continue
 			case "img":
-				if err := transformImage(token, writer); err != nil {
+				if err := transformImage(token, writer, images); err != nil {
 					return handleErr(err)
 				}
 				continue
@@ -627,14 +1193,29 @@ func transformAsciicast(asciicastToken html.Token, writer *bytes.Buffer) (asciic
 	return meta, nil
 }
 
-func transformImage(imageToken html.Token, writer *bytes.Buffer) error {
+func transformImage(imageToken html.Token, writer *bytes.Buffer, images *imageSet) error {
+	if transformed, err := transformMediaImage(imageToken, writer, images); transformed || err != nil {
+		return err
+	}
+
+	imageToken, err := lazyImage(imageToken)
+	if err != nil {
+		return err
+	}
+	writer.WriteString(imageToken.String())
+	return nil
+}
+
+// lazyImage adds `loading="lazy"` to images with a width and height, as
+// these won't cause the layout to shift once they've been loaded.
+func lazyImage(imageToken html.Token) (html.Token, error) {
 	_, hasWidth := attr(imageToken, "width")
 	_, hasHeight := attr(imageToken, "height")
 
 	loading, _ := attr(imageToken, "loading")
 	if loading == "lazy" {
 		if !hasWidth || !hasHeight {
-			return fmt.Errorf("image tag '%s' is set to load lazy, but doesn't have a width and height", imageToken.String())
+			return imageToken, fmt.Errorf("image tag '%s' is set to load lazy, but doesn't have a width and height, which can only be determined automatically for images in the media directory", imageToken.String())
 		}
 	}
 
@@ -644,16 +1225,16 @@ func transformImage(imageToken html.Token, writer *bytes.Buffer) error {
 		imageToken.Attr = append(imageToken.Attr, html.Attribute{Key: "loading", Val: "lazy"})
 	}
 
-	writer.WriteString(imageToken.String())
-	return nil
+	return imageToken, nil
 }
 
-func transformHeading(tokenizer *html.Tokenizer, headingOpen html.Token, writer *bytes.Buffer) error {
+func transformHeading(tokenizer *html.Tokenizer, headingOpen html.Token, writer *bytes.Buffer) (heading, error) {
+	result := heading{Level: int(headingOpen.Data[1] - '0')}
 	var lastText string
 	for {
 		tokenType, token, err := next(tokenizer)
 		if err != nil {
-			return err
+			return result, err
 		}
 
 		switch tokenType {
@@ -666,11 +1247,13 @@ func transformHeading(tokenizer *html.Tokenizer, headingOpen html.Token, writer
 				writer.WriteString(headingOpen.String())
 				writer.WriteString(lastText)
 				writer.WriteString(fmt.Sprintf(`<a class="h-a" href="#%s">#</a>`, id))
+				result.Id = id
+				result.Text = html.UnescapeString(lastText)
 				lastText = ""
 			}
 
 			writer.WriteString(token.String())
-			return nil
+			return result, nil
 		default:
 			writer.WriteString(token.String())
 		}
@@ -687,164 +1270,96 @@ func cleanup(output string) error {
 		filepath.Join(output, "media"),
 		filepath.Join(output, "articles"),
 		filepath.Join(output, "pages"),
+		filepath.Join(output, "feeds"),
+		filepath.Join(output, "series"),
 		filepath.Join(output, "favicon.ico"),
 		filepath.Join(output, "favicon.png"),
 		filepath.Join(output, "base.css"),
+		filepath.Join(output, "highlight.css"),
 		filepath.Join(output, "404.html"),
 		filepath.Join(output, "feed.xml"),
+		filepath.Join(output, "atom.xml"),
+		filepath.Join(output, "feed.json"),
+		filepath.Join(output, "sitemap.xml"),
+		filepath.Join(output, "robots.txt"),
+		filepath.Join(output, "search.html"),
+		filepath.Join(output, "archive.html"),
+		filepath.Join(output, "tags.html"),
+		filepath.Join(output, "search.js"),
+		filepath.Join(output, "search-index.json"),
+		filepath.Join(output, "asciinema-player.min.js"),
+		filepath.Join(output, "asciinema-player.css"),
 	); err != nil {
 		return err
 	}
-	files, err := filepath.Glob(filepath.Join(output, "index*.html"))
-	if err != nil {
-		return fmt.Errorf("couldn't delete old index*.html files: %w", err)
-	}
-	for _, indexToDelete := range files {
-		os.Remove(indexToDelete)
+	for _, pattern := range []string{"index*.html", "archive-*.html"} {
+		files, err := filepath.Glob(filepath.Join(output, pattern))
+		if err != nil {
+			return fmt.Errorf("couldn't delete old %s files: %w", pattern, err)
+		}
+		for _, fileToDelete := range files {
+			os.Remove(fileToDelete)
+		}
 	}
 
 	return nil
 }
 
-// writeIndexFiles writes paginated index files. It supports both tagged
-// index files and untagged (default) index files.
+// writeIndexFiles writes paginated index files and returns their names. It
+// supports both tagged index files and untagged (default) index files.
 func writeIndexFiles(
 	indexTemplate *template.Template,
 	indexedArticles []*indexedArticle,
-	customPages []*customPageEntry,
-	loadedPageConfig blogConfig,
-	tags []string,
-	filterTag string,
+	// baseData contains everything that is the same on each page, the
+	// paging information and articles are filled in here.
+	baseData indexData,
 	firstIndexName string,
 	indexNameTemplate string,
 	outputFolder string,
 	minifyOutput bool,
-) error {
+	manifest *buildManifest,
+	sharedHash string,
+) ([]string, error) {
+	maxIndexEntries := baseData.MaxIndexEntries
 	currentPageNumber := 1
-	lastPageNumber := len(indexedArticles) / loadedPageConfig.MaxIndexEntries
-	if len(indexedArticles)%loadedPageConfig.MaxIndexEntries != 0 {
+	lastPageNumber := len(indexedArticles) / maxIndexEntries
+	if len(indexedArticles)%maxIndexEntries != 0 || len(indexedArticles) == 0 {
 		lastPageNumber++
 	}
 
-	for i := 1; i <= len(indexedArticles); i += loadedPageConfig.MaxIndexEntries {
+	var pageNames []string
+	// Even without any articles, the first page is written, as other pages
+	// link to it.
+	for i := 1; i == 1 || i <= len(indexedArticles); i += maxIndexEntries {
 		var pageName string
 		if currentPageNumber == 1 {
 			pageName = firstIndexName
 		} else {
 			pageName = fmt.Sprintf(indexNameTemplate, currentPageNumber)
 		}
-		data := &indexData{
-			blogConfig:       loadedPageConfig,
-			Tags:             tags,
-			FilterTag:        filterTag,
-			CustomPages:      customPages,
-			IndexedArticles:  indexedArticles[i-1 : min(i-1+loadedPageConfig.MaxIndexEntries, len(indexedArticles))],
-			PageNameTemplate: indexNameTemplate,
-			CurrentPageNum:   currentPageNumber,
-			FirstPage:        firstIndexName,
-			LastPageNum:      lastPageNumber,
-		}
-		if i+loadedPageConfig.MaxIndexEntries <= len(indexedArticles) {
+		data := baseData
+		data.IndexedArticles = indexedArticles[i-1 : min(i-1+maxIndexEntries, len(indexedArticles))]
+		data.PageNameTemplate = indexNameTemplate
+		data.CurrentPageNum = currentPageNumber
+		data.FirstPage = firstIndexName
+		data.LastPageNum = lastPageNumber
+		if i+maxIndexEntries <= len(indexedArticles) {
 			data.NextPageNum = currentPageNumber + 1
 		}
 		if currentPageNumber > 1 {
 			data.PrevPageNum = currentPageNumber - 1
 		}
 
-		if err := writeTemplateToFile(indexTemplate, data, outputFolder, pageName, minifyOutput); err != nil {
-			return nil
-		}
-		currentPageNumber++
-	}
-
-	return nil
-}
-
-func writeRSSFeed(sourceFolder, outputFolder string, articles []*indexedArticle, loadedPageConfig blogConfig) error {
-	var mainAuthor *feeds.Author
-	if loadedPageConfig.Email != "" {
-		mainAuthor = &feeds.Author{
-			Name:  loadedPageConfig.Author,
-			Email: loadedPageConfig.Email,
-		}
-	}
-	feed := &feeds.Feed{
-		Title:       loadedPageConfig.SiteName,
-		Description: loadedPageConfig.Description,
-		Author:      mainAuthor,
-	}
-	if loadedPageConfig.URL != "" {
-		feed.Link = &feeds.Link{Href: loadedPageConfig.URL}
-	}
-	if loadedPageConfig.CreationDate != "" {
-		var err error
-		feed.Created, err = time.Parse(time.RFC3339, loadedPageConfig.CreationDate)
-		if err != nil {
-			return err
-		}
-	}
-
-	for _, article := range articles {
-		newFeedItem := &feeds.Item{
-			Title:       article.Title,
-			Author:      mainAuthor,
-			Content:     article.FeedContent,
-			Description: article.Description,
-			Created:     article.RFC3339Time,
-		}
-		if article.AuthorEmail != "" || article.AuthorName != "" {
-			articleAuthor := &feeds.Author{
-				Name: article.AuthorName,
-			}
-			if article.AuthorEmail != "" {
-				articleAuthor.Email = article.AuthorEmail
-			} else if mainAuthor != nil {
-				articleAuthor.Email = mainAuthor.Email
-			}
-			newFeedItem.Author = articleAuthor
-		}
-		if article.podcastAudio != "" {
-			audioFilepath := filepath.Join(sourceFolder, article.podcastAudio)
-			audioFile, err := os.Stat(audioFilepath)
-			if err != nil {
-				return fmt.Errorf("couldn't read podcast audio file '%s': %w", audioFilepath, err)
-			}
-			audioURL, err := joinURLParts(feed.Link.Href, article.podcastAudio)
-			if err != nil {
-				return fmt.Errorf("couldn't generate audio URL: %w", err)
-			}
-			newFeedItem.Enclosure = &feeds.Enclosure{
-				Type:   "audio/mp3",
-				Length: strconv.FormatInt(audioFile.Size(), 10),
-				Url:    audioURL,
-			}
-		}
-		feed.Items = append(feed.Items, newFeedItem)
-		if loadedPageConfig.URL != "" {
-			articleURL, err := joinURLParts(loadedPageConfig.URL, article.File)
-			if err != nil {
-				return fmt.Errorf("couldn't generate article URL: %w", err)
+		if !manifest.upToDate(pageName, hashInputs(sharedHash, data)) {
+			if err := writeTemplateToFile(indexTemplate, &data, outputFolder, pageName, minifyOutput); err != nil {
+				return nil, err
 			}
-			newFeedItem.Link = &feeds.Link{Href: articleURL}
 		}
+		pageNames = append(pageNames, pageName)
+		currentPageNumber++
 	}
 
-	rssFile, err := createFile(filepath.Join(outputFolder, "feed.xml"))
-	if err != nil {
-		return err
-	}
-	defer rssFile.Close()
-
-	rssData, err := feed.ToRss()
-	if err != nil {
-		return fmt.Errorf("couldn't generate RSS feed: %w", err)
-	}
-	_, err = rssFile.WriteString(rssData)
-	if err != nil {
-		return fmt.Errorf("couldn't write RSS feed: %w", err)
-	}
-
-	return nil
+	return pageNames, nil
 }
 
 // joinURLParts puts together two URL pieces without duplicating separators
@@ -862,6 +1377,13 @@ func joinURLParts(partOne, partTwo string) (string, error) {
 	return url.String(), nil
 }
 
+// absoluteURL turns a path relative to the output directory into an URL.
+// The configured URL already points to the output directory, so it includes
+// the BasePath, if any.
+func absoluteURL(loadedPageConfig blogConfig, file string) (string, error) {
+	return joinURLParts(loadedPageConfig.URL, file)
+}
+
 type blogConfig struct {
 	BasePath string
 	// Hidden will not show any links to the given page. This works for both
@@ -880,6 +1402,66 @@ type blogConfig struct {
 	MaxIndexEntries     int
 	AddOptionalMetaData bool
 	Favicon             string
+	// CodeStyle is the name of the style used for highlighting code. The
+	// available styles can be found at https://xyproto.github.io/splash/docs/.
+	CodeStyle string
+	// CodeStyleDark is used instead of CodeStyle, if the user prefers a dark
+	// color scheme.
+	CodeStyleDark string
+	// Search adds a search page, which requires JavaScript.
+	Search bool
+	// TableOfContents adds a table of contents to each article. Articles can
+	// override this via the `toc` header.
+	TableOfContents bool
+	// Tags optionally defines a display name and description for tags. The
+	// description is shown on the index page of the tag.
+	Tags map[string]tagConfig
+	// MaxRelatedArticles limits the number of related articles shown below
+	// each article. Set to 0 to disable related articles.
+	MaxRelatedArticles int
+	// TimeZone is the IANA name of the timezone used for article dates
+	// without an explicit offset, for example "Europe/Berlin". Defaults to
+	// UTC.
+	TimeZone string
+	// ImageWidths are the widths at which resized copies of PNG and JPEG
+	// images in the media directory are generated.
+	ImageWidths []int
+	// ImageSizes is the sizes attribute of images with resized copies.
+	ImageSizes string
+	// ImageWebP additionally generates lossless WebP copies of PNG images.
+	ImageWebP bool
+	// Slugs defines how tags, series and the slug header of articles are
+	// turned into file names.
+	Slugs slugConfig
+	// Language is the BCP 47 tag of the blog's language, for example "de"
+	// or "en-GB". Pages in other languages set the `lang` header.
+	Language string
+	// Translations override the built-in strings of the templates per
+	// language, for example {"de": {"Archive": "Archiv"}}.
+	Translations map[string]map[string]string
+
+	// The following fields are derived from the language of each page, see
+	// blogConfig.localize.
+
+	// Locale is the Language in the format used by og:locale.
+	Locale string `json:"-"`
+	// Strings are the built-in strings in the Language of the page.
+	Strings map[string]string `json:"-"`
+	// LanguageDir contains the index pages and feeds of the Language. It's
+	// empty for the main language and ends with a slash otherwise.
+	LanguageDir string `json:"-"`
+}
+
+// pendingArticle has been parsed, but not written yet, see Build.
+type pendingArticle struct {
+	// indexed is nil for hidden articles.
+	indexed             *indexedArticle
+	name                string
+	targetPath          string
+	rawContent          []byte
+	data                *articlePageData
+	showTableOfContents bool
+	translationKey      string
 }
 
 type customPageEntry struct {
@@ -887,15 +1469,17 @@ type customPageEntry struct {
 	File  string
 	// Hidden will not show any links to the given page. This works for both
 	// custom pages and articles.
-	Hidden   bool
-	data     *customPageData
-	template *template.Template
+	Hidden  bool
+	data    *customPageData
+	content []byte
 }
 
 type articlePageData struct {
 	blogConfig
 	// Time article was published in RFC3339 format.
 	RFC3339Time string
+	// RFC3339Updated is the time of the last significant change, if any.
+	RFC3339Updated string
 	// HumanTime is a human readable time format.
 	HumanTime string
 	// PodcastAudio file link
@@ -909,6 +1493,25 @@ type articlePageData struct {
 	// the page, automatically causing the generator to add the required scripts
 	// and stylesheets.
 	Asciicasts []asciicastMeta
+	// HighlightedCode causes highlight.css to be included.
+	HighlightedCode bool
+	// Scheduled marks articles that aren't published yet, see
+	// BuildOptions.MarkScheduled.
+	Scheduled bool
+	// Series is only set if the article is part of a series.
+	Series *seriesNavigation
+	// PreviousArticle is the next older article, if any.
+	PreviousArticle *articleLink
+	// NextArticle is the next newer article, if any.
+	NextArticle *articleLink
+	// RelatedArticles share at least one tag with the article.
+	RelatedArticles []articleLink
+	// TableOfContents is the outline of the article's headings. It is only
+	// set if enabled via config or article header.
+	TableOfContents []*tocEntry
+	// Alternates are all variants of the article in different languages,
+	// including the article itself. Only set if there's more than one.
+	Alternates []languageLink
 }
 
 type customPageData struct {
@@ -917,17 +1520,26 @@ type customPageData struct {
 	// CustomPages are listed right of the default pages in the site navbar /
 	// header.
 	CustomPages []*customPageEntry
+	// HighlightedCode causes highlight.css to be included.
+	HighlightedCode bool
 }
 
 type indexData struct {
 	blogConfig
 	// Tags are all available tags used accross all posts
-	Tags []string
+	Tags []*tagInfo
 	// FilterTag that is currently filtered for
 	FilterTag string
+	// FilterTagInfo contains the name and description of the FilterTag.
+	FilterTagInfo *tagInfo
+	// FeedFile is the feed containing only the articles of the FilterTag.
+	FeedFile string
 	// CustomPages are listed right of the default pages in the site navbar /
 	// header.
 	CustomPages []*customPageEntry
+	// Alternates are the main index pages of all languages, if the blog has
+	// articles in more than one language.
+	Alternates []languageLink
 	// IndexedArticles are the articles to display.
 	IndexedArticles []*indexedArticle
 
@@ -943,13 +1555,26 @@ type indexData struct {
 
 type indexedArticle struct {
 	blogConfig
-	AuthorName   string
-	AuthorEmail  string
-	Title        string
-	File         string
-	RFC3339Time  time.Time
+	AuthorName  string
+	AuthorEmail string
+	Title       string
+	File        string
+	RFC3339Time time.Time
+	// Updated is zero, unless the article has been updated after publishing.
+	Updated time.Time
+	// podcastAudio is the path of the audio file relative to the source
+	// directory.
 	podcastAudio string
-	HumanTime    string
-	FeedContent  string
-	Tags         []string
+	// searchText is the plain text content, only set if search is enabled.
+	searchText string
+	HumanTime  string
+	// FeedContent is excluded from the manifest hashes of index pages, as it
+	// isn't displayed there.
+	FeedContent string `json:"-"`
+	Tags        []string
+	// Scheduled marks articles that aren't published yet, see
+	// BuildOptions.MarkScheduled.
+	Scheduled bool
+	// translationKey links the variants of the article in other languages.
+	translationKey string
 }
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/goccy/go-yaml"
+)
+
+// configNames are looked for in the source directory, unless a config has
+// been passed explicitly.
+var configNames = []string{"config.json", "config.yaml", "config.yml", "config.toml"}
+
+const (
+	defaultDateFormat      = "2 January 2006"
+	defaultMaxIndexEntries = 10
+)
+
+// configEnvPrefix is the prefix of environment variables overriding
+// settings, for example STASI_BLOG_URL or STASI_BLOG_SLUGS_SEPARATOR.
+const configEnvPrefix = "STASI_BLOG_"
+
+// findConfig returns the path of the config in the source directory. Having
+// more than one config is an error, as it's unclear which one is used.
+func findConfig(sourceDir string) (string, error) {
+	var found []string
+	for _, name := range configNames {
+		configPath := filepath.Join(sourceDir, name)
+		if _, err := os.Stat(configPath); err == nil {
+			found = append(found, configPath)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("error looking for config: %w", err)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("no config found in '%s', expected one of %s", sourceDir, strings.Join(configNames, ", "))
+	case 1:
+		return found[0], nil
+	default:
+		return "", fmt.Errorf("found multiple configs (%s), only one is allowed", strings.Join(found, ", "))
+	}
+}
+
+// defaultConfig contains the defaults for all settings missing in the
+// config.
+func defaultConfig() blogConfig {
+	return blogConfig{
+		DateFormat:         defaultDateFormat,
+		MaxIndexEntries:    defaultMaxIndexEntries,
+		MaxRelatedArticles: defaultMaxRelatedArticles,
+		CodeStyle:          defaultCodeStyle,
+		CodeStyleDark:      defaultCodeStyleDark,
+		ImageSizes:         defaultImageSizes,
+		Language:           defaultLanguage,
+	}
+}
+
+// loadConfig reads the config at the given path, applies the defaults for
+// all missing settings and validates the result. All problems found during
+// validation are reported at once. Settings can be overridden via
+// environment variables and overrides in the format "Key=Value", where the
+// latter take precedence.
+func loadConfig(configPath string, overrides []string) (blogConfig, error) {
+	config := defaultConfig()
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return config, fmt.Errorf("error loading config '%s': %w", configPath, err)
+	}
+	problems, err := decodeConfig(data, filepath.Ext(configPath), &config)
+	if err != nil {
+		return config, fmt.Errorf("error decoding config '%s': %w", configPath, err)
+	}
+
+	for _, variable := range os.Environ() {
+		name, value, _ := strings.Cut(variable, "=")
+		key, isOverride := strings.CutPrefix(name, configEnvPrefix)
+		if !isOverride {
+			continue
+		}
+		// Setting names don't contain underscores, so they can be used to
+		// separate nested settings.
+		err := config.set(strings.ReplaceAll(key, "_", "."), value)
+		// Unrelated variables might share the prefix, for example in CI
+		// pipelines, so these don't fail the build. Unlike --set, they
+		// might not have been meant for us.
+		if errors.Is(err, errUnknownSetting) {
+			log.Printf("Warning: ignoring environment variable '%s': %s\n", name, err)
+		} else if err != nil {
+			return config, fmt.Errorf("error applying environment variable '%s': %w", name, err)
+		}
+	}
+	for _, override := range overrides {
+		key, value, valid := strings.Cut(override, "=")
+		if !valid {
+			return config, fmt.Errorf("override '%s' has to be in the format Key=Value", override)
+		}
+		if err := config.set(key, value); err != nil {
+			return config, fmt.Errorf("error applying override '%s': %w", override, err)
+		}
+	}
+
+	if config.BasePath != "" {
+		// Making sure there's not too many or too little slashes ;)
+		config.BasePath = "/" + strings.Trim(config.BasePath, `/\`)
+	}
+
+	if err := errors.Join(append(problems, config.validate())...); err != nil {
+		return config, fmt.Errorf("invalid config '%s':\n%w", configPath, err)
+	}
+	return config, nil
+}
+
+// decodeConfig decodes the config, which is either JSON, YAML or TOML,
+// depending on the file extension. Unknown settings are most likely typos,
+// which would otherwise silently be ignored. They're returned as problems
+// separately from the error, so they can be reported together with other
+// problems of the config.
+func decodeConfig(data []byte, extension string, config *blogConfig) ([]error, error) {
+	// YAML and TOML are converted to JSON, so that all formats use the same
+	// field names and validation.
+	jsonData := data
+	switch strings.ToLower(extension) {
+	case ".yaml", ".yml":
+		var err error
+		jsonData, err = yaml.YAMLToJSON(data)
+		if err != nil {
+			return nil, err
+		}
+	case ".toml":
+		var values map[string]any
+		if _, err := toml.Decode(string(data), &values); err != nil {
+			return nil, err
+		}
+		var err error
+		jsonData, err = json.Marshal(values)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Offsets within the converted JSON are meaningless for the user.
+	isJSON := bytes.Equal(jsonData, data)
+
+	err := json.Unmarshal(jsonData, config)
+	var syntaxError *json.SyntaxError
+	if errors.As(err, &syntaxError) {
+		return nil, fmt.Errorf("%s: %w", position(data, syntaxError.Offset), err)
+	}
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) {
+		message := fmt.Sprintf("'%s' has to be of type %s, but is %s", typeError.Field, typeError.Type, typeError.Value)
+		if isJSON {
+			message = position(data, typeError.Offset) + ": " + message
+		}
+		return nil, errors.New(message)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []error
+	for _, setting := range unknownSettings(jsonData, reflect.TypeOf(blogConfig{}), "") {
+		name := setting[strings.LastIndexByte(setting, '.')+1:]
+		message := fmt.Sprintf("unknown setting '%s'", setting)
+		if isJSON {
+			if offset := bytes.Index(data, []byte(strconv.Quote(name))); offset != -1 {
+				message = position(data, int64(offset)) + ": " + message
+			}
+		}
+		if suggestion := suggest(name, configFieldNames()); suggestion != "" {
+			message += fmt.Sprintf(", did you mean '%s'?", suggestion)
+		}
+		problems = append(problems, errors.New(message))
+	}
+	return problems, nil
+}
+
+// unknownSettings returns the dot separated paths of all keys in the JSON
+// value, that don't match any field of the given type. Just like in
+// encoding/json, case is ignored. Keys of maps, such as the tags in Tags,
+// are arbitrary, but their values are checked.
+func unknownSettings(data json.RawMessage, settingType reflect.Type, prefix string) []string {
+	for settingType.Kind() == reflect.Pointer {
+		settingType = settingType.Elem()
+	}
+
+	var unknown []string
+	switch settingType.Kind() {
+	case reflect.Slice:
+		var values []json.RawMessage
+		if json.Unmarshal(data, &values) != nil {
+			return nil
+		}
+		for _, value := range values {
+			unknown = append(unknown, unknownSettings(value, settingType.Elem(), prefix)...)
+		}
+	case reflect.Map:
+		var values map[string]json.RawMessage
+		if json.Unmarshal(data, &values) != nil {
+			return nil
+		}
+		for _, key := range sortedKeys(values) {
+			unknown = append(unknown, unknownSettings(values[key], settingType.Elem(), prefix+key+".")...)
+		}
+	case reflect.Struct:
+		var values map[string]json.RawMessage
+		if json.Unmarshal(data, &values) != nil {
+			return nil
+		}
+	KEYS:
+		for _, key := range sortedKeys(values) {
+			for _, field := range reflect.VisibleFields(settingType) {
+				name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+				if name == "" {
+					name = field.Name
+				}
+				if field.IsExported() && !field.Anonymous && name != "-" && strings.EqualFold(key, name) {
+					unknown = append(unknown, unknownSettings(values[key], field.Type, prefix+name+".")...)
+					continue KEYS
+				}
+			}
+			unknown = append(unknown, prefix+key)
+		}
+	}
+	return unknown
+}
+
+func sortedKeys(values map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// position converts a byte offset into a human readable line and column.
+func position(data []byte, offset int64) string {
+	offset = min(max(offset, 0), int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Sprintf("line %d, column %d", line, column)
+}
+
+// validate checks all settings that would otherwise cause a failure late
+// during the build or silently produce broken output.
+func (config *blogConfig) validate() error {
+	var problems []error
+	addProblem := func(format string, arguments ...any) {
+		problems = append(problems, fmt.Errorf(format, arguments...))
+	}
+
+	if config.URL != "" {
+		parsedURL, err := url.Parse(config.URL)
+		if err != nil {
+			addProblem("URL '%s' is invalid: %w", config.URL, err)
+		} else if (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+			addProblem("URL '%s' has to be absolute, for example 'https://example.com'", config.URL)
+		}
+	}
+	if config.CreationDate != "" {
+		if _, err := time.Parse(time.RFC3339, config.CreationDate); err != nil {
+			addProblem("CreationDate '%s' has to be in RFC3339 format, for example '2021-02-28T00:00:00+00:00'", config.CreationDate)
+		}
+	}
+	// A layout without any of the reference values is returned as is,
+	// which is the case for formats such as "dd.mm.yyyy".
+	if config.DateFormat == "" || time.Unix(0, 0).UTC().Format(config.DateFormat) == config.DateFormat {
+		addProblem("DateFormat '%s' doesn't contain a date, see https://pkg.go.dev/time#pkg-constants", config.DateFormat)
+	}
+	if config.MaxIndexEntries <= 0 {
+		addProblem("MaxIndexEntries has to be greater than 0, but is %d", config.MaxIndexEntries)
+	}
+	if config.MaxRelatedArticles < 0 {
+		addProblem("MaxRelatedArticles can't be negative, use 0 to disable related articles")
+	}
+	for _, width := range config.ImageWidths {
+		if width <= 0 {
+			addProblem("ImageWidths have to be greater than 0, but contain %d", width)
+		}
+	}
+	for _, style := range []string{config.CodeStyle, config.CodeStyleDark} {
+		if _, exists := styles.Registry[style]; !exists {
+			message := fmt.Sprintf("code style '%s' doesn't exist", style)
+			if suggestion := suggest(style, styles.Names()); suggestion != "" {
+				message += fmt.Sprintf(", did you mean '%s'?", suggestion)
+			}
+			problems = append(problems, errors.New(message))
+		}
+	}
+	if config.TimeZone != "" {
+		if _, err := time.LoadLocation(config.TimeZone); err != nil {
+			addProblem("TimeZone '%s' is unknown, use an IANA name such as 'Europe/Berlin'", config.TimeZone)
+		}
+	}
+	if language, err := parseLanguage(config.Language); err != nil {
+		addProblem("Language: %w", err)
+	} else {
+		config.Language = language
+	}
+	if config.Slugs.Separator != "" && strings.Trim(config.Slugs.Separator, "-_.") != "" {
+		addProblem("Slugs.Separator '%s' may only consist of '-', '_' and '.'", config.Slugs.Separator)
+	}
+
+	return errors.Join(problems...)
+}
+
+// errUnknownSetting is returned by set for names that don't match any
+// setting.
+var errUnknownSetting = errors.New("unknown setting")
+
+// set overrides a single setting, such as "URL" or "Slugs.Separator". Just
+// like in the config, names are case insensitive. Values of settings that
+// aren't strings are parsed as JSON, for example "true" or "[480, 960]".
+func (config *blogConfig) set(key, value string) error {
+	setting := reflect.ValueOf(config).Elem()
+	names := strings.Split(key, ".")
+	for depth, name := range names {
+		// Maps such as Tags can't be addressed, as their keys may contain
+		// dots themselves.
+		if setting.Kind() == reflect.Map {
+			return fmt.Errorf("%w '%s', '%s' can only be replaced as a whole", errUnknownSetting, key, strings.Join(names[:depth], "."))
+		}
+		if setting.Kind() != reflect.Struct {
+			return fmt.Errorf("%w '%s', '%s' doesn't have any nested settings", errUnknownSetting, key, strings.Join(names[:depth], "."))
+		}
+
+		var fieldNames []string
+		var found bool
+		for index := 0; index < setting.NumField(); index++ {
+			field := setting.Type().Field(index)
+			if !field.IsExported() || field.Tag.Get("json") == "-" {
+				continue
+			}
+			if strings.EqualFold(field.Name, name) {
+				setting, found = setting.Field(index), true
+				break
+			}
+			fieldNames = append(fieldNames, field.Name)
+		}
+		if !found {
+			if suggestion := suggest(name, fieldNames); suggestion != "" {
+				return fmt.Errorf("%w '%s', did you mean '%s'?", errUnknownSetting, name, suggestion)
+			}
+			return fmt.Errorf("%w '%s'", errUnknownSetting, name)
+		}
+	}
+
+	if setting.Kind() == reflect.String {
+		setting.SetString(value)
+		return nil
+	}
+	// Unmarshalling into a fresh value prevents merging maps and lists.
+	parsed := reflect.New(setting.Type())
+	if err := json.Unmarshal([]byte(value), parsed.Interface()); err != nil {
+		return fmt.Errorf("invalid value for '%s': %w", key, err)
+	}
+	setting.Set(parsed.Elem())
+	return nil
+}
+
+// configFieldNames returns the names of all settings, including those of
+// nested settings, such as the fields of Tags.
+func configFieldNames() []string {
+	var names []string
+	var collect func(configType reflect.Type)
+	collect = func(configType reflect.Type) {
+		for configType.Kind() == reflect.Map || configType.Kind() == reflect.Slice || configType.Kind() == reflect.Pointer {
+			configType = configType.Elem()
+		}
+		if configType.Kind() != reflect.Struct {
+			return
+		}
+		for _, field := range reflect.VisibleFields(configType) {
+			if !field.IsExported() || field.Anonymous || field.Tag.Get("json") == "-" {
+				continue
+			}
+			names = append(names, field.Name)
+			collect(field.Type)
+		}
+	}
+	collect(reflect.TypeOf(blogConfig{}))
+	return names
+}
+
+// suggest returns the candidate closest to the given name, if any is
+// reasonably close. Case is ignored, just like encoding/json does.
+func suggest(name string, candidates []string) string {
+	// Sorting makes the result deterministic if multiple candidates are
+	// equally close.
+	candidates = append([]string(nil), candidates...)
+	sort.Strings(candidates)
+
+	lowerName := strings.ToLower(name)
+	bestDistance := max(2, len(name)/3) + 1
+	var best string
+	for _, candidate := range candidates {
+		if distance := editDistance(lowerName, strings.ToLower(candidate)); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	runesA, runesB := []rune(a), []rune(b)
+	previous := make([]int, len(runesB)+1)
+	current := make([]int, len(runesB)+1)
+	for indexB := range previous {
+		previous[indexB] = indexB
+	}
+	for indexA := 1; indexA <= len(runesA); indexA++ {
+		current[0] = indexA
+		for indexB := 1; indexB <= len(runesB); indexB++ {
+			cost := 1
+			if runesA[indexA-1] == runesB[indexB-1] {
+				cost = 0
+			}
+			current[indexB] = min(previous[indexB]+1, current[indexB-1]+1, previous[indexB-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(runesB)]
+}
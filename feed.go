@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/Bios-Marcel/feeds"
+)
+
+// createFeed produces a format agnostic feed, which can then be written as
+// RSS, Atom or JSON Feed.
+func createFeed(sourceFolder string, articles []*indexedArticle, loadedPageConfig blogConfig) (*feeds.Feed, error) {
+	var mainAuthor *feeds.Author
+	if loadedPageConfig.Email != "" {
+		mainAuthor = &feeds.Author{
+			Name:  loadedPageConfig.Author,
+			Email: loadedPageConfig.Email,
+		}
+	}
+	feed := &feeds.Feed{
+		Title:       loadedPageConfig.SiteName,
+		Description: loadedPageConfig.Description,
+		Author:      mainAuthor,
+	}
+	if loadedPageConfig.URL != "" {
+		homepageURL, err := absoluteURL(loadedPageConfig, "/"+loadedPageConfig.LanguageDir)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't generate homepage URL: %w", err)
+		}
+		feed.Link = &feeds.Link{Href: homepageURL}
+	}
+	if loadedPageConfig.CreationDate != "" {
+		var err error
+		feed.Created, err = time.Parse(time.RFC3339, loadedPageConfig.CreationDate)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Without this, the Atom feed would contain the time of the build, causing
+	// it to change with every build.
+	for _, article := range articles {
+		if article.RFC3339Time.After(feed.Updated) {
+			feed.Updated = article.RFC3339Time
+		}
+		if article.Updated.After(feed.Updated) {
+			feed.Updated = article.Updated
+		}
+	}
+
+	for _, article := range articles {
+		newFeedItem := &feeds.Item{
+			Title:       article.Title,
+			Author:      mainAuthor,
+			Content:     article.FeedContent,
+			Description: article.Description,
+			Created:     article.RFC3339Time,
+			Updated:     article.Updated,
+			// Used if there's no URL, as JSON feed requires an ID.
+			Id: article.File,
+		}
+		if article.AuthorEmail != "" || article.AuthorName != "" {
+			articleAuthor := &feeds.Author{
+				Name: article.AuthorName,
+			}
+			if article.AuthorEmail != "" {
+				articleAuthor.Email = article.AuthorEmail
+			} else if mainAuthor != nil {
+				articleAuthor.Email = mainAuthor.Email
+			}
+			newFeedItem.Author = articleAuthor
+		}
+		if article.podcastAudio != "" {
+			audioFilepath := filepath.Join(sourceFolder, article.podcastAudio)
+			audioFile, err := os.Stat(audioFilepath)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't read podcast audio file '%s': %w", audioFilepath, err)
+			}
+			audioURL, err := absoluteURL(loadedPageConfig, article.podcastAudio)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't generate audio URL: %w", err)
+			}
+			audioType := mime.TypeByExtension(path.Ext(article.podcastAudio))
+			if audioType == "" {
+				audioType = "audio/mpeg"
+			}
+			newFeedItem.Enclosure = &feeds.Enclosure{
+				Type:   audioType,
+				Length: strconv.FormatInt(audioFile.Size(), 10),
+				Url:    audioURL,
+			}
+		}
+		feed.Items = append(feed.Items, newFeedItem)
+		if loadedPageConfig.URL != "" {
+			articleURL, err := absoluteURL(loadedPageConfig, article.File)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't generate article URL: %w", err)
+			}
+			newFeedItem.Link = &feeds.Link{Href: articleURL}
+			newFeedItem.Id = articleURL
+		}
+	}
+
+	return feed, nil
+}
+
+// writeFeeds writes the feed as RSS (feed.xml), Atom (atom.xml) and JSON
+// Feed (feed.json) into the given directory relative to the output folder.
+// The language is only supported by RSS and JSON Feed.
+func writeFeeds(outputFolder, directory string, feed *feeds.Feed, language string, manifest *buildManifest) error {
+	rssFeed := (&feeds.Rss{Feed: feed}).RssFeed()
+	rssFeed.Language = language
+	rssData, err := feeds.ToXML(rssFeed)
+	if err != nil {
+		return fmt.Errorf("couldn't generate RSS feed: %w", err)
+	}
+	if err := writeFileIfChanged(outputFolder, path.Join(directory, "feed.xml"), rssData, manifest); err != nil {
+		return fmt.Errorf("couldn't write RSS feed: %w", err)
+	}
+
+	atomData, err := feed.ToAtom()
+	if err != nil {
+		return fmt.Errorf("couldn't generate Atom feed: %w", err)
+	}
+	if err := writeFileIfChanged(outputFolder, path.Join(directory, "atom.xml"), atomData, manifest); err != nil {
+		return fmt.Errorf("couldn't write Atom feed: %w", err)
+	}
+
+	jsonFeed := createJSONFeed(feed)
+	jsonFeed.Language = language
+	jsonData, err := jsonFeed.ToJSON()
+	if err != nil {
+		return fmt.Errorf("couldn't generate JSON feed: %w", err)
+	}
+	if err := writeFileIfChanged(outputFolder, path.Join(directory, "feed.json"), jsonData, manifest); err != nil {
+		return fmt.Errorf("couldn't write JSON feed: %w", err)
+	}
+
+	return nil
+}
+
+// createJSONFeed converts the feed into JSON Feed 1.1. The feeds library
+// doesn't handle enclosures other than images, so we fill in the gaps.
+func createJSONFeed(feed *feeds.Feed) *feeds.JSONFeed {
+	jsonFeed := (&feeds.JSON{Feed: feed}).JSONFeed()
+	for index, item := range feed.Items {
+		jsonItem := jsonFeed.Items[index]
+		if item.Enclosure != nil && jsonItem.Image == "" {
+			size, _ := strconv.ParseInt(item.Enclosure.Length, 10, 32)
+			jsonItem.Attachments = append(jsonItem.Attachments, feeds.JSONAttachment{
+				Url:      item.Enclosure.Url,
+				MIMEType: item.Enclosure.Type,
+				Size:     int32(size),
+			})
+		}
+	}
+
+	return jsonFeed
+}
@@ -59,6 +59,23 @@ func writeTemplateToFile(
 	return nil
 }
 
+// writeFileIfChanged writes the data to the file relative to the output
+// folder, unless the file already contains the same data.
+func writeFileIfChanged(outputFolder, file, data string, manifest *buildManifest) error {
+	if manifest.upToDate(file, hashInputs(data)) {
+		return nil
+	}
+
+	target, err := createFile(filepath.Join(outputFolder, file))
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	_, err = target.WriteString(data)
+	return err
+}
+
 func copyDataIntoFile(source io.Reader, targetPath string) error {
 	target, err := createFile(targetPath)
 	if err != nil {
@@ -1,26 +1,28 @@
 module github.com/Bios-Marcel/stasi-blog
 
-go 1.22
-
-toolchain go1.22.2
+go 1.22.2
 
 require (
 	github.com/Bios-Marcel/feeds v1.1.3
+	github.com/BurntSushi/toml v1.5.0
+	github.com/HugoSmits86/nativewebp v1.2.0
 	github.com/NYTimes/gziphandler v1.1.1
+	github.com/alecthomas/chroma/v2 v2.14.0
 	github.com/bep/debounce v1.2.1
 	github.com/fsnotify/fsnotify v1.8.0
 	github.com/goccy/go-yaml v1.15.23
-	github.com/otiai10/copy v1.14.1
 	github.com/spf13/cobra v1.9.1
 	github.com/tdewolff/minify/v2 v2.21.3
+	github.com/yuin/goldmark v1.7.8
+	golang.org/x/image v0.24.0
 	golang.org/x/net v0.35.0
+	golang.org/x/text v0.22.0
 )
 
 require (
+	github.com/dlclark/regexp2 v1.11.0 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/otiai10/mint v1.6.3 // indirect
 	github.com/spf13/pflag v1.0.6 // indirect
 	github.com/tdewolff/parse/v2 v2.7.19 // indirect
-	golang.org/x/sync v0.8.0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
 )
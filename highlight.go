@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"golang.org/x/net/html"
+)
+
+const (
+	defaultCodeStyle     = "github"
+	defaultCodeStyleDark = "github-dark"
+)
+
+// classFormatter produces HTML referencing the classes defined in
+// highlight.css, which is generated by writeHighlightCSS.
+var classFormatter = chromahtml.New(chromahtml.WithClasses(true))
+
+// inlineFormatter produces HTML with inline styles, which is required
+// wherever we can't add a stylesheet, such as in feeds.
+var inlineFormatter = chromahtml.New(chromahtml.WithClasses(false))
+
+// codeLanguage returns the language of a code block, which is defined by
+// the class "language-xxx" on the code element. This is the convention used
+// by Markdown renderers and the HTML specification.
+func codeLanguage(codeToken html.Token) string {
+	classes, _ := attr(codeToken, "class")
+	for _, class := range strings.Fields(classes) {
+		if language, ok := strings.CutPrefix(class, "language-"); ok {
+			return language
+		}
+	}
+	return ""
+}
+
+// transformCodeBlock highlights `<pre><code class="language-xxx">` blocks.
+// Blocks without a language, with an unknown language or with markup inside
+// of the code element are written unchanged. The returned bool indicates
+// whether the block has been highlighted.
+func transformCodeBlock(
+	tokenizer *html.Tokenizer,
+	preOpen html.Token,
+	writer *bytes.Buffer,
+	// inlineStyle causes the output to use inline styles instead of classes.
+	inlineStyle *chroma.Style,
+) (bool, error) {
+	// Everything we've read is kept, in case we can't highlight the block.
+	rawTokens := []string{preOpen.String()}
+	writeUnchanged := func() {
+		for _, rawToken := range rawTokens {
+			writer.WriteString(rawToken)
+		}
+	}
+
+	var codeOpen html.Token
+	for {
+		tokenType, token, err := next(tokenizer)
+		if err != nil {
+			writeUnchanged()
+			return false, err
+		}
+
+		rawTokens = append(rawTokens, token.String())
+		if tokenType == html.TextToken && strings.TrimSpace(token.Data) == "" {
+			continue
+		}
+		if tokenType != html.StartTagToken || token.Data != "code" {
+			writeUnchanged()
+			return false, nil
+		}
+		codeOpen = token
+		break
+	}
+
+	lexer := lexers.Get(codeLanguage(codeOpen))
+	var code strings.Builder
+	for {
+		tokenType, token, err := next(tokenizer)
+		if err != nil {
+			writeUnchanged()
+			return false, err
+		}
+
+		rawTokens = append(rawTokens, token.String())
+		if tokenType == html.EndTagToken && token.Data == "code" {
+			break
+		}
+		if tokenType != html.TextToken {
+			// Markup inside of the code, such as manual highlighting,
+			// is left alone.
+			lexer = nil
+			continue
+		}
+		code.WriteString(token.Data)
+	}
+
+	if lexer == nil {
+		writeUnchanged()
+		return false, nil
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code.String())
+	if err != nil {
+		return false, fmt.Errorf("error tokenising code: %w", err)
+	}
+
+	// The formatter writes its own pre and code elements.
+	formatter, style := classFormatter, styles.Fallback
+	if inlineStyle != nil {
+		formatter, style = inlineFormatter, inlineStyle
+	}
+	if err := formatter.Format(writer, style, iterator); err != nil {
+		return false, fmt.Errorf("error highlighting code: %w", err)
+	}
+
+	// Skip the closing pre element, as it has already been written.
+	for {
+		tokenType, token, err := next(tokenizer)
+		if err != nil {
+			return true, err
+		}
+		if tokenType == html.EndTagToken && token.Data == "pre" {
+			return true, nil
+		}
+		if tokenType != html.TextToken || strings.TrimSpace(token.Data) != "" {
+			writer.WriteString(token.String())
+		}
+	}
+}
+
+// writeHighlightCSS writes the stylesheet for the highlighted code blocks,
+// using the dark style if the user prefers a dark color scheme, just like
+// base.css.
+func writeHighlightCSS(writer io.Writer, lightStyle, darkStyle string) error {
+	if err := classFormatter.WriteCSS(writer, styles.Get(lightStyle)); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(writer, "\n@media (prefers-color-scheme: dark) {\n"); err != nil {
+		return err
+	}
+	if err := classFormatter.WriteCSS(writer, styles.Get(darkStyle)); err != nil {
+		return err
+	}
+	_, err := io.WriteString(writer, "}\n")
+	return err
+}
+
+// writeHighlightCSSFile writes highlight.css into the output directory. A
+// theme can replace the generated file with its own highlight.css.
+func writeHighlightCSSFile(theme *theme, outputDir string, loadedPageConfig blogConfig, minifyOutput bool) error {
+	css := &bytes.Buffer{}
+	themeCSS, err := os.ReadFile(filepath.Join(theme.dir, "highlight.css"))
+	if err == nil {
+		css.Write(themeCSS)
+	} else if errors.Is(err, fs.ErrNotExist) {
+		if err := writeHighlightCSS(css, loadedPageConfig.CodeStyle, loadedPageConfig.CodeStyleDark); err != nil {
+			return err
+		}
+	} else {
+		return err
+	}
+
+	output, err := createFile(filepath.Join(outputDir, "highlight.css"))
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	if minifyOutput {
+		return minifier.Minify("text/css", output, css)
+	}
+	_, err = css.WriteTo(output)
+	return err
+}
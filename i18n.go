@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// defaultLanguage is used unless Language is configured.
+const defaultLanguage = "en-GB"
+
+// catalogs contain the built-in strings of the templates, keyed by the base
+// language. Strings missing in a catalog fall back to English. Some strings
+// are format strings, where `%s` and `%d` are replaced with values such as
+// the date of an article.
+var catalogs = map[string]map[string]string{
+	"en": {
+		"LanguageName":              "English",
+		"Feed":                      "RSS-Feed",
+		"TagFeed":                   "RSS-Feed for %s",
+		"Archive":                   "Archive",
+		"ArchiveYear":               "Archive %d",
+		"Tags":                      "Tags",
+		"TagArticles":               "%s articles",
+		"Search":                    "Search",
+		"SearchArticles":            "Search articles",
+		"SearchRequiresJavaScript":  "Searching requires JavaScript.",
+		"NoSearchResults":           "No articles found.",
+		"WrittenOn":                 "Written on %s",
+		"WrittenBy":                 "by %s",
+		"Scheduled":                 "Scheduled",
+		"AudioUnsupported":          "Your browser is unable to play this audio.",
+		"Contents":                  "Contents",
+		"SeriesPart":                "Part %d of %d of the series",
+		"SeriesParts":               "This series consists of %d parts.",
+		"RelatedArticles":           "Related articles",
+		"Translations":              "Also available in",
+		"Languages":                 "Languages",
+		"CommentsRequireJavaScript": "If you wish to access the comment section, you need to enable JavaScript.",
+		"CommentsOnGitHub":          "Alternatively, you can try reading the comment directly on GitHub:",
+		"FindComments":              "Find comments for %s",
+		"First":                     "First",
+		"Last":                      "Last",
+		"NoArticles":                "There are no articles yet.",
+		"NoTags":                    "There are no tags yet.",
+		"NotFound":                  "The page you requested could not be found.",
+		"January":                   "January",
+		"February":                  "February",
+		"March":                     "March",
+		"April":                     "April",
+		"May":                       "May",
+		"June":                      "June",
+		"July":                      "July",
+		"August":                    "August",
+		"September":                 "September",
+		"October":                   "October",
+		"November":                  "November",
+		"December":                  "December",
+	},
+	"de": {
+		"LanguageName":              "Deutsch",
+		"Feed":                      "RSS-Feed",
+		"TagFeed":                   "RSS-Feed für %s",
+		"Archive":                   "Archiv",
+		"ArchiveYear":               "Archiv %d",
+		"Tags":                      "Tags",
+		"TagArticles":               "Artikel zu %s",
+		"Search":                    "Suche",
+		"SearchArticles":            "Artikel durchsuchen",
+		"SearchRequiresJavaScript":  "Die Suche benötigt JavaScript.",
+		"NoSearchResults":           "Keine Artikel gefunden.",
+		"WrittenOn":                 "Geschrieben am %s",
+		"WrittenBy":                 "von %s",
+		"Scheduled":                 "Geplant",
+		"AudioUnsupported":          "Dein Browser kann diese Audiodatei nicht abspielen.",
+		"Contents":                  "Inhalt",
+		"SeriesPart":                "Teil %d von %d der Serie",
+		"SeriesParts":               "Diese Serie besteht aus %d Teilen.",
+		"RelatedArticles":           "Ähnliche Artikel",
+		"Translations":              "Auch verfügbar auf",
+		"Languages":                 "Sprachen",
+		"CommentsRequireJavaScript": "Um die Kommentare zu sehen, musst du JavaScript aktivieren.",
+		"CommentsOnGitHub":          "Alternativ kannst du die Kommentare direkt auf GitHub lesen:",
+		"FindComments":              "Kommentare zu %s finden",
+		"First":                     "Erste",
+		"Last":                      "Letzte",
+		"NoArticles":                "Es gibt noch keine Artikel.",
+		"NoTags":                    "Es gibt noch keine Tags.",
+		"NotFound":                  "Die angeforderte Seite konnte nicht gefunden werden.",
+		"January":                   "Januar",
+		"February":                  "Februar",
+		"March":                     "März",
+		"April":                     "April",
+		"May":                       "Mai",
+		"June":                      "Juni",
+		"July":                      "Juli",
+		"August":                    "August",
+		"September":                 "September",
+		"October":                   "Oktober",
+		"November":                  "November",
+		"December":                  "Dezember",
+	},
+}
+
+// languageLink points to the variant of a page in a specific language.
+type languageLink struct {
+	Language string
+	// Name is the name of the language in the language itself.
+	Name string
+	File string
+	// Href is absolute if a URL has been configured, as search engines
+	// require this for hreflang alternates.
+	Href string
+}
+
+// parseLanguage validates and normalises a BCP 47 language tag, so that
+// for example "de-de" and "de-DE" are treated as the same language.
+func parseLanguage(value string) (string, error) {
+	tag, err := language.Parse(strings.TrimSpace(value))
+	if err != nil {
+		return "", fmt.Errorf("invalid language '%s': %w", value, err)
+	}
+	return tag.String(), nil
+}
+
+// localizedStrings merges the English catalog, the built-in catalog of the
+// base language and the configured translations, in that order.
+func localizedStrings(languageTag string, translations map[string]map[string]string) map[string]string {
+	base, _ := language.Make(languageTag).Base()
+
+	result := make(map[string]string, len(catalogs["en"]))
+	for key, value := range catalogs["en"] {
+		result[key] = value
+	}
+	catalog, builtIn := catalogs[base.String()]
+	for key, value := range catalog {
+		result[key] = value
+	}
+	if !builtIn {
+		result["LanguageName"] = languageTag
+	}
+
+	// Translations for "de" also apply to "de-AT", but the more specific
+	// ones win.
+	for _, key := range []string{base.String(), languageTag} {
+		for configuredLanguage, configured := range translations {
+			if !strings.EqualFold(configuredLanguage, key) {
+				continue
+			}
+			for key, value := range configured {
+				result[key] = value
+			}
+		}
+		if base.String() == languageTag {
+			break
+		}
+	}
+
+	return result
+}
+
+// localize sets the language of a single page and all fields derived from
+// it. mainLanguage is the configured language of the blog.
+func (config *blogConfig) localize(languageTag, mainLanguage string) {
+	config.Language = languageTag
+	config.Locale = strings.ReplaceAll(languageTag, "-", "_")
+	config.Strings = localizedStrings(languageTag, config.Translations)
+	config.LanguageDir = languageDir(languageTag, mainLanguage)
+}
+
+// formatDate formats the time using the layout and translates full month
+// names, such as "January". Abbreviated month names and weekdays are always
+// written in English.
+func formatDate(date time.Time, layout string, localized map[string]string) string {
+	formatted := date.Format(layout)
+	month := date.Month().String()
+	if translated := localized[month]; translated != "" && strings.Contains(layout, "January") {
+		formatted = strings.ReplaceAll(formatted, month, translated)
+	}
+	return formatted
+}
+
+// languageDir contains the index pages and feeds of a language. The main
+// language uses the root of the output directory.
+func languageDir(languageTag, mainLanguage string) string {
+	if languageTag == mainLanguage {
+		return ""
+	}
+	return strings.ToLower(languageTag) + "/"
+}
+
+// newLanguageLink creates a link to the given file, which is relative to the
+// output directory.
+func newLanguageLink(config blogConfig, languageTag, file string) (languageLink, error) {
+	link := languageLink{
+		Language: languageTag,
+		Name:     localizedStrings(languageTag, config.Translations)["LanguageName"],
+		File:     file,
+		Href:     config.BasePath + "/" + file,
+	}
+	if config.URL != "" {
+		var err error
+		link.Href, err = absoluteURL(config, file)
+		if err != nil {
+			return link, fmt.Errorf("couldn't generate URL for '%s': %w", file, err)
+		}
+	}
+	return link, nil
+}
+
+// linkTranslations sets the Alternates of all articles sharing a
+// translation key. Hidden articles aren't linked, as they're not meant to
+// be discovered.
+func linkTranslations(articles []*pendingArticle, config blogConfig) error {
+	variantsByKey := make(map[string][]*pendingArticle)
+	for _, article := range articles {
+		if article.indexed == nil || article.translationKey == "" {
+			continue
+		}
+		variantsByKey[article.translationKey] = append(variantsByKey[article.translationKey], article)
+	}
+
+	for key, variants := range variantsByKey {
+		if len(variants) < 2 {
+			continue
+		}
+
+		variantsByLanguage := make(map[string]*pendingArticle, len(variants))
+		alternates := make([]languageLink, 0, len(variants))
+		for _, variant := range variants {
+			variantLanguage := variant.data.Language
+			if other, exists := variantsByLanguage[variantLanguage]; exists {
+				return fmt.Errorf("articles '%s' and '%s' both use the translation key '%s' for the language '%s'", other.name, variant.name, key, variantLanguage)
+			}
+			variantsByLanguage[variantLanguage] = variant
+
+			link, err := newLanguageLink(config, variantLanguage, variant.indexed.File)
+			if err != nil {
+				return err
+			}
+			alternates = append(alternates, link)
+		}
+
+		sort.Slice(alternates, func(a, b int) bool {
+			return alternates[a].Language < alternates[b].Language
+		})
+		for _, variant := range variants {
+			variant.data.Alternates = alternates
+		}
+	}
+
+	return nil
+}
+
+// languageGroup contains all articles written in a single language.
+type languageGroup struct {
+	language string
+	articles []*indexedArticle
+}
+
+// groupLanguages groups the articles by language, starting with the main
+// language, followed by all others in alphabetical order. Languages only
+// used by hidden articles or custom pages are included as well, since the
+// header of each page links to the index of its language.
+func groupLanguages(
+	mainLanguage string,
+	customPages []*customPageEntry,
+	pendingArticles []*pendingArticle,
+	indexedArticles []*indexedArticle,
+) []*languageGroup {
+	groupsByLanguage := map[string]*languageGroup{
+		mainLanguage: {language: mainLanguage},
+	}
+	groups := []*languageGroup{groupsByLanguage[mainLanguage]}
+	addLanguage := func(language string) {
+		if _, exists := groupsByLanguage[language]; !exists {
+			groupsByLanguage[language] = &languageGroup{language: language}
+			groups = append(groups, groupsByLanguage[language])
+		}
+	}
+	for _, page := range customPages {
+		addLanguage(page.data.Language)
+	}
+	for _, article := range pendingArticles {
+		addLanguage(article.data.Language)
+	}
+
+	// indexedArticles are already sorted, so each group is as well.
+	for _, article := range indexedArticles {
+		group := groupsByLanguage[article.Language]
+		group.articles = append(group.articles, article)
+	}
+
+	sort.Slice(groups[1:], func(a, b int) bool {
+		return groups[a+1].language < groups[b+1].language
+	})
+	return groups
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"golang.org/x/net/html"
+)
+
+// defaultImageSizes matches the width of the content column in base.css.
+const defaultImageSizes = "(max-width: 720px) 95vw, min(50rem, 90vw)"
+
+// jpegQuality is used for all resized JPEG images.
+const jpegQuality = 85
+
+// imageSet contains the dimensions of all images in the media directory and
+// the resized copies, if any were generated.
+type imageSet struct {
+	basePath string
+	// sizes is used for the sizes attribute of images, unless the image has
+	// an explicit width.
+	sizes string
+	// images are keyed by their slash separated path relative to the source
+	// directory, for example `media/house.png`.
+	images map[string]*mediaImage
+}
+
+type mediaImage struct {
+	Width  int
+	Height int
+	// Variants are resized copies in the original format, sorted by width.
+	Variants []imageVariant
+	// WebPVariants are only generated for PNG images, as the WebP encoder
+	// only supports lossless compression, which doesn't beat JPEG.
+	WebPVariants []imageVariant
+}
+
+type imageVariant struct {
+	Width int
+	// File is relative to the output directory.
+	File string
+}
+
+func newImageSet(loadedPageConfig blogConfig) *imageSet {
+	return &imageSet{
+		basePath: loadedPageConfig.BasePath,
+		sizes:    loadedPageConfig.ImageSizes,
+		images:   make(map[string]*mediaImage),
+	}
+}
+
+// lookup resolves the src attribute of an image. Sources pointing into the
+// media directory can be written with or without basepath, for example
+// `{{.BasePath}}/media/house.png` or `/media/house.png`. The returned prefix
+// is everything in front of `media/` and can be used to reference the
+// variants the same way the original image is referenced.
+func (images *imageSet) lookup(src string) (*mediaImage, string) {
+	if images == nil {
+		return nil, ""
+	}
+
+	index := strings.Index(src, "media/")
+	if index == -1 {
+		return nil, ""
+	}
+
+	prefix := src[:index]
+	rest := strings.Trim(strings.TrimPrefix(prefix, "{{.BasePath}}"), "/")
+	if rest != "" && rest != ".." && rest != strings.Trim(images.basePath, "/") {
+		return nil, ""
+	}
+
+	image := images.images[src[index:]]
+	if image == nil {
+		return nil, ""
+	}
+	return image, prefix
+}
+
+// isImageFile reports whether the dimensions of the image can be determined.
+// Vector graphics such as SVG don't have intrinsic dimensions.
+func isImageFile(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".webp":
+		return true
+	}
+	return false
+}
+
+func isResizableImage(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".png", ".jpg", ".jpeg":
+		return true
+	}
+	return false
+}
+
+// variantName produces the name of a resized image, for example
+// `media/house-480w.png`.
+func variantName(file string, width int, extension string) string {
+	return strings.TrimSuffix(file, path.Ext(file)) + "-" + strconv.Itoa(width) + "w" + extension
+}
+
+// readMediaImage determines the dimensions of an image. Only the header is
+// read, so this is cheap, even for big images.
+func readMediaImage(sourcePath string) (*mediaImage, error) {
+	file, err := os.Open(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	// Without proper dimensions, neither the aspect ratio nor any resized
+	// copies can be calculated.
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, fmt.Errorf("invalid dimensions %dx%d", config.Width, config.Height)
+	}
+	return &mediaImage{Width: config.Width, Height: config.Height}, nil
+}
+
+// processMediaImage writes resized variants of a PNG or JPEG image at all
+// configured widths, that are smaller than the image itself. The image is
+// only decoded if any of the variants is out of date.
+func processMediaImage(
+	result *mediaImage,
+	sourcePath, relativePath, sourceHash, outputDir string,
+	loadedPageConfig blogConfig,
+	manifest *buildManifest,
+) error {
+	config := image.Config{Width: result.Width, Height: result.Height}
+
+	var decoded image.Image
+	resized := func(width int) (image.Image, error) {
+		if decoded == nil {
+			file, err := os.Open(sourcePath)
+			if err != nil {
+				return nil, err
+			}
+			defer file.Close()
+
+			decoded, _, err = image.Decode(file)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding image '%s': %w", relativePath, err)
+			}
+		}
+		if width == config.Width {
+			return decoded, nil
+		}
+
+		height := (config.Height*width + config.Width/2) / config.Width
+		target := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(target, target.Bounds(), decoded, decoded.Bounds(), draw.Src, nil)
+		return target, nil
+	}
+
+	widths := slices.Clone(loadedPageConfig.ImageWidths)
+	slices.Sort(widths)
+	widths = slices.Compact(widths)
+
+	extension := path.Ext(relativePath)
+	isPNG := strings.EqualFold(extension, ".png")
+	for _, width := range widths {
+		if width <= 0 || width >= config.Width {
+			continue
+		}
+
+		variant := imageVariant{Width: width, File: variantName(relativePath, width, extension)}
+		result.Variants = append(result.Variants, variant)
+		if manifest.upToDate(variant.File, hashInputs(sourceHash, width)) {
+			continue
+		}
+
+		resizedImage, err := resized(width)
+		if err != nil {
+			return err
+		}
+
+		var buffer bytes.Buffer
+		if isPNG {
+			err = png.Encode(&buffer, resizedImage)
+		} else {
+			err = jpeg.Encode(&buffer, resizedImage, &jpeg.Options{Quality: jpegQuality})
+		}
+		if err != nil {
+			return fmt.Errorf("error encoding image '%s': %w", variant.File, err)
+		}
+		if err := writeImage(outputDir, variant.File, &buffer); err != nil {
+			return err
+		}
+	}
+
+	if isPNG && loadedPageConfig.ImageWebP {
+		webPWidths := []int{config.Width}
+		for _, variant := range result.Variants {
+			webPWidths = append(webPWidths, variant.Width)
+		}
+		slices.Sort(webPWidths)
+
+		for _, width := range webPWidths {
+			variant := imageVariant{Width: width, File: variantName(relativePath, width, ".webp")}
+			result.WebPVariants = append(result.WebPVariants, variant)
+			if manifest.upToDate(variant.File, hashInputs(sourceHash, width)) {
+				continue
+			}
+
+			resizedImage, err := resized(width)
+			if err != nil {
+				return err
+			}
+
+			var buffer bytes.Buffer
+			if err := nativewebp.Encode(&buffer, resizedImage, nil); err != nil {
+				return fmt.Errorf("error encoding image '%s': %w", variant.File, err)
+			}
+			if err := writeImage(outputDir, variant.File, &buffer); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func writeImage(outputDir, file string, data *bytes.Buffer) error {
+	targetPath := filepath.Join(outputDir, filepath.FromSlash(file))
+	if err := createDirectories(filepath.Dir(targetPath)); err != nil {
+		return err
+	}
+
+	output, err := createFile(targetPath)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	_, err = data.WriteTo(output)
+	return err
+}
+
+// srcset produces the value for a srcset attribute. The original image is
+// optional, as the WebP variants already contain a full size copy.
+func srcset(prefix string, variants []imageVariant, original string, originalWidth int) string {
+	candidates := make([]string, 0, len(variants)+1)
+	for _, variant := range variants {
+		candidates = append(candidates, fmt.Sprintf("%s%s %dw", prefix, variant.File, variant.Width))
+	}
+	if original != "" {
+		candidates = append(candidates, fmt.Sprintf("%s %dw", original, originalWidth))
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// transformMediaImage fills in the intrinsic dimensions of images from the
+// media directory and adds a srcset to images that have resized variants.
+// If WebP variants exist, the image is wrapped in a picture element. The
+// returned bool indicates whether the image has been written.
+func transformMediaImage(imageToken html.Token, writer *bytes.Buffer, images *imageSet) (bool, error) {
+	src, _ := attr(imageToken, "src")
+	mediaImage, prefix := images.lookup(src)
+	if mediaImage == nil {
+		return false, nil
+	}
+	// Images with a handwritten srcset are left alone.
+	if _, hasSrcset := attr(imageToken, "srcset"); hasSrcset {
+		withoutVariants := *mediaImage
+		withoutVariants.Variants, withoutVariants.WebPVariants = nil, nil
+		mediaImage = &withoutVariants
+	}
+
+	// If the author specified a width, the image is never shown any larger.
+	sizes := images.sizes
+	width, hasWidth := attr(imageToken, "width")
+	height, hasHeight := attr(imageToken, "height")
+	if hasWidth {
+		if _, err := strconv.Atoi(width); err == nil {
+			sizes = width + "px"
+		}
+	}
+	imageToken.Attr = append(imageToken.Attr, intrinsicSize(mediaImage, width, hasWidth, height, hasHeight)...)
+
+	if len(mediaImage.Variants) > 0 {
+		imageToken.Attr = append(imageToken.Attr,
+			html.Attribute{Key: "srcset", Val: srcset(prefix, mediaImage.Variants, src, mediaImage.Width)},
+			html.Attribute{Key: "sizes", Val: sizes})
+	}
+	imageToken, err := lazyImage(imageToken)
+	if err != nil {
+		return true, err
+	}
+	if len(mediaImage.WebPVariants) == 0 {
+		writer.WriteString(imageToken.String())
+		return true, nil
+	}
+
+	source := html.Token{
+		Type: html.StartTagToken,
+		Data: "source",
+		Attr: []html.Attribute{
+			{Key: "type", Val: "image/webp"},
+			{Key: "srcset", Val: srcset(prefix, mediaImage.WebPVariants, "", 0)},
+			{Key: "sizes", Val: sizes},
+		},
+	}
+	writer.WriteString("<picture>")
+	writer.WriteString(source.String())
+	writer.WriteString(imageToken.String())
+	writer.WriteString("</picture>")
+	return true, nil
+}
+
+// intrinsicSize returns the width and height attributes missing from an
+// image. If only one of them is given, the other is derived from the aspect
+// ratio.
+func intrinsicSize(mediaImage *mediaImage, width string, hasWidth bool, height string, hasHeight bool) []html.Attribute {
+	switch {
+	case hasWidth && hasHeight:
+		return nil
+	case hasWidth:
+		if width, err := strconv.Atoi(width); err == nil {
+			return []html.Attribute{{Key: "height", Val: strconv.Itoa((mediaImage.Height*width + mediaImage.Width/2) / mediaImage.Width)}}
+		}
+		return nil
+	case hasHeight:
+		if height, err := strconv.Atoi(height); err == nil {
+			return []html.Attribute{{Key: "width", Val: strconv.Itoa((mediaImage.Width*height + mediaImage.Height/2) / mediaImage.Height)}}
+		}
+		return nil
+	}
+
+	return []html.Attribute{
+		{Key: "width", Val: strconv.Itoa(mediaImage.Width)},
+		{Key: "height", Val: strconv.Itoa(mediaImage.Height)},
+	}
+}
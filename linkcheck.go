@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// linkAttributes defines which attributes of which elements contain links.
+var linkAttributes = map[string][]string{
+	"a":      {"href"},
+	"area":   {"href"},
+	"link":   {"href"},
+	"img":    {"src", "srcset"},
+	"source": {"src", "srcset"},
+	"script": {"src"},
+	"audio":  {"src"},
+	"video":  {"src", "poster"},
+	"track":  {"src"},
+	"iframe": {"src"},
+	"embed":  {"src"},
+}
+
+type linkDiagnostic struct {
+	// File is relative to the output directory.
+	File    string
+	Line    int
+	Message string
+}
+
+func (diagnostic linkDiagnostic) String() string {
+	return fmt.Sprintf("%s:%d: %s", diagnostic.File, diagnostic.Line, diagnostic.Message)
+}
+
+type foundLink struct {
+	file   string
+	line   int
+	target string
+}
+
+// linkChecker verifies all links of a built site that point to the site
+// itself. External links aren't checked, as that would require network
+// access and be slow.
+type linkChecker struct {
+	outputDir string
+	basePath  string
+	// siteURL is optional. If set, absolute links starting with it are
+	// treated as internal links.
+	siteURL string
+	// ids contains all element ids per HTML file, so fragments can be
+	// verified.
+	ids   map[string]map[string]struct{}
+	links []foundLink
+}
+
+// checkLinks walks all HTML files and feeds in the output directory and
+// returns a diagnostic for each broken internal link, sorted by file and
+// line.
+func checkLinks(outputDir, basePath, siteURL string) ([]linkDiagnostic, error) {
+	checker := &linkChecker{
+		outputDir: outputDir,
+		basePath:  strings.Trim(basePath, `/\`),
+		siteURL:   strings.TrimSuffix(siteURL, "/"),
+		ids:       make(map[string]map[string]struct{}),
+	}
+
+	err := filepath.WalkDir(outputDir, func(filePath string, dirEntry fs.DirEntry, err error) error {
+		if err != nil || dirEntry.IsDir() {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(outputDir, filePath)
+		if err != nil {
+			return err
+		}
+		relativePath = filepath.ToSlash(relativePath)
+
+		switch path.Ext(relativePath) {
+		case ".html":
+			return checker.parseHTML(filePath, relativePath)
+		case ".xml":
+			return checker.parseXML(filePath, relativePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var diagnostics []linkDiagnostic
+	for _, link := range checker.links {
+		if message := checker.verify(link); message != "" {
+			diagnostics = append(diagnostics, linkDiagnostic{
+				File:    link.file,
+				Line:    link.line,
+				Message: message,
+			})
+		}
+	}
+
+	sort.SliceStable(diagnostics, func(a, b int) bool {
+		if diagnostics[a].File != diagnostics[b].File {
+			return diagnostics[a].File < diagnostics[b].File
+		}
+		return diagnostics[a].Line < diagnostics[b].Line
+	})
+	return diagnostics, nil
+}
+
+func (checker *linkChecker) parseHTML(filePath, relativePath string) error {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	ids := make(map[string]struct{})
+	checker.ids[relativePath] = ids
+
+	line := 1
+	tokenizer := html.NewTokenizer(bytes.NewReader(content))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			if errors.Is(tokenizer.Err(), io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("error parsing '%s': %w", relativePath, tokenizer.Err())
+		}
+
+		// Raw has to be read before Token, as Token modifies the buffer.
+		tokenLine := line
+		line += bytes.Count(tokenizer.Raw(), []byte("\n"))
+
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+
+		token := tokenizer.Token()
+		if id, ok := attr(token, "id"); ok {
+			ids[id] = struct{}{}
+		}
+		// Named anchors are the legacy way of defining fragments.
+		if name, ok := attr(token, "name"); ok && token.Data == "a" {
+			ids[name] = struct{}{}
+		}
+
+		for _, attribute := range linkAttributes[token.Data] {
+			value, ok := attr(token, attribute)
+			if !ok {
+				continue
+			}
+
+			if attribute == "srcset" {
+				for _, candidate := range strings.Split(value, ",") {
+					if fields := strings.Fields(candidate); len(fields) > 0 {
+						checker.links = append(checker.links, foundLink{relativePath, tokenLine, fields[0]})
+					}
+				}
+				continue
+			}
+			checker.links = append(checker.links, foundLink{relativePath, tokenLine, value})
+		}
+	}
+}
+
+// parseXML collects the links of feeds and sitemaps. Only absolute links are
+// used there, which are only checked if the site URL is known.
+func (checker *linkChecker) parseXML(filePath, relativePath string) error {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	lineAt := func(offset int64) int {
+		return bytes.Count(content[:offset], []byte("\n")) + 1
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	var textTarget string
+	var textLine int
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("error parsing '%s': %w", relativePath, err)
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			textTarget = ""
+			switch token.Name.Local {
+			case "link", "loc":
+				textTarget = token.Name.Local
+				textLine = lineAt(offset)
+			}
+			for _, attribute := range token.Attr {
+				if (token.Name.Local == "link" && attribute.Name.Local == "href") ||
+					(token.Name.Local == "enclosure" && attribute.Name.Local == "url") {
+					checker.links = append(checker.links, foundLink{relativePath, lineAt(offset), attribute.Value})
+				}
+			}
+		case xml.CharData:
+			if textTarget != "" {
+				if target := strings.TrimSpace(string(token)); target != "" {
+					checker.links = append(checker.links, foundLink{relativePath, textLine, target})
+				}
+			}
+		case xml.EndElement:
+			textTarget = ""
+		}
+	}
+}
+
+// verify returns a message describing what's wrong with the link or an empty
+// string if the link is fine.
+func (checker *linkChecker) verify(link foundLink) string {
+	target := link.target
+	// Links that still contain template actions weren't expanded.
+	if strings.Contains(target, "{{") {
+		return fmt.Sprintf("unexpanded template in link '%s'", target)
+	}
+
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return fmt.Sprintf("invalid link '%s': %s", target, err)
+	}
+
+	targetPath := parsed.Path
+	if parsed.Scheme != "" || parsed.Host != "" {
+		if checker.siteURL == "" || !strings.HasPrefix(target, checker.siteURL+"/") {
+			// External link
+			return ""
+		}
+		site, err := url.Parse(checker.siteURL)
+		if err != nil {
+			return ""
+		}
+		// The site URL points to the output directory, which is served at
+		// the basepath.
+		targetPath = "/" + path.Join(checker.basePath, strings.TrimPrefix(parsed.Path, site.Path))
+	}
+
+	var file string
+	switch {
+	case targetPath == "":
+		// Fragment only, for example "#heading".
+		file = link.file
+	case strings.HasPrefix(targetPath, "/"):
+		file = strings.TrimPrefix(targetPath, "/")
+		if checker.basePath != "" {
+			withoutBasePath, ok := strings.CutPrefix(file, checker.basePath)
+			if !ok || (withoutBasePath != "" && !strings.HasPrefix(withoutBasePath, "/")) {
+				return fmt.Sprintf("link '%s' doesn't start with the basepath '/%s'", target, checker.basePath)
+			}
+			file = strings.TrimPrefix(withoutBasePath, "/")
+		}
+	default:
+		file = path.Join(path.Dir(link.file), targetPath)
+		if file == ".." || strings.HasPrefix(file, "../") {
+			return fmt.Sprintf("link '%s' points outside of the site", target)
+		}
+	}
+
+	file = path.Clean("/" + file)[1:]
+	if stat, err := os.Stat(filepath.Join(checker.outputDir, filepath.FromSlash(file))); err != nil {
+		return fmt.Sprintf("broken link '%s'", target)
+	} else if stat.IsDir() {
+		file = path.Join(file, "index.html")
+		if _, err := os.Stat(filepath.Join(checker.outputDir, filepath.FromSlash(file))); err != nil {
+			return fmt.Sprintf("broken link '%s', the directory doesn't contain an index.html", target)
+		}
+	}
+
+	if parsed.Fragment == "" {
+		return ""
+	}
+	ids, isHTML := checker.ids[file]
+	if !isHTML {
+		return ""
+	}
+	if _, exists := ids[parsed.Fragment]; !exists {
+		return fmt.Sprintf("link '%s' points to a non-existent anchor", target)
+	}
+	return ""
+}
+
+// reportBrokenLinks prints all diagnostics and fails if there are any.
+func reportBrokenLinks(diagnostics []linkDiagnostic) error {
+	for _, diagnostic := range diagnostics {
+		fmt.Fprintln(os.Stderr, diagnostic)
+	}
+	if len(diagnostics) > 0 {
+		return fmt.Errorf("found %d broken link(s)", len(diagnostics))
+	}
+	return nil
+}
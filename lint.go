@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"golang.org/x/net/html"
+)
+
+// voidElements never have a closing tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// optionalEndTags are closed implicitly by browsers, so leaving out their
+// closing tag isn't a mistake.
+var optionalEndTags = map[string]bool{
+	"p": true, "li": true, "dt": true, "dd": true, "tr": true, "td": true,
+	"th": true, "thead": true, "tbody": true, "tfoot": true, "option": true,
+	"optgroup": true, "colgroup": true, "rt": true, "rp": true,
+}
+
+type lintDiagnostic struct {
+	// File is relative to the source directory.
+	File string
+	// Line is 0 if the problem can't be attributed to a line, for example
+	// for Markdown, which is only checked after rendering.
+	Line    int
+	Message string
+}
+
+func (diagnostic lintDiagnostic) String() string {
+	if diagnostic.Line == 0 {
+		return fmt.Sprintf("%s: %s", diagnostic.File, diagnostic.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", diagnostic.File, diagnostic.Line, diagnostic.Message)
+}
+
+// linter collects the problems of all articles and custom pages of a site.
+type linter struct {
+	sourceDir    string
+	location     *time.Location
+	images       *imageSet
+	headerKeys   []string
+	titleOwners  map[string]string
+	diagnostics  []lintDiagnostic
+	mainLanguage string
+}
+
+// lintSource parses all articles and custom pages the same way the build
+// does, including drafts, and returns a diagnostic for each problem found,
+// sorted by file and line.
+func lintSource(sourceDir, configPath string, overrides []string) ([]lintDiagnostic, error) {
+	var err error
+	if configPath == "" {
+		configPath, err = findConfig(sourceDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	config, err := loadConfig(configPath, overrides)
+	if err != nil {
+		return nil, err
+	}
+	location := time.UTC
+	if config.TimeZone != "" {
+		location, err = time.LoadLocation(config.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("error loading TimeZone '%s': %w", config.TimeZone, err)
+		}
+	}
+
+	images, err := readMediaDimensions(sourceDir, config)
+	if err != nil {
+		return nil, fmt.Errorf("error reading media directory: %w", err)
+	}
+
+	linter := &linter{
+		sourceDir:    sourceDir,
+		location:     location,
+		images:       images,
+		headerKeys:   headerKeys(),
+		titleOwners:  make(map[string]string),
+		mainLanguage: config.Language,
+	}
+	for _, directory := range []string{"pages", "articles"} {
+		entries, err := os.ReadDir(filepath.Join(sourceDir, directory))
+		if err != nil {
+			return nil, fmt.Errorf("couldn't handle %s directory: %w", directory, err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !isPageFile(entry.Name()) {
+				continue
+			}
+			if err := linter.lintPage(path.Join(directory, entry.Name()), directory == "articles"); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sort.SliceStable(linter.diagnostics, func(a, b int) bool {
+		if linter.diagnostics[a].File != linter.diagnostics[b].File {
+			return linter.diagnostics[a].File < linter.diagnostics[b].File
+		}
+		return linter.diagnostics[a].Line < linter.diagnostics[b].Line
+	})
+	return linter.diagnostics, nil
+}
+
+// readMediaDimensions reads the dimensions of all images in the media
+// directory, so that these images aren't reported for lacking a width and
+// height, which the build adds automatically.
+func readMediaDimensions(sourceDir string, config blogConfig) (*imageSet, error) {
+	images := newImageSet(config)
+	err := filepath.WalkDir(
+		filepath.Join(sourceDir, "media"),
+		func(sourcePath string, dirEntry fs.DirEntry, err error) error {
+			if err != nil || dirEntry.IsDir() || !isImageFile(sourcePath) {
+				return err
+			}
+
+			relativePath, err := filepath.Rel(sourceDir, sourcePath)
+			if err != nil {
+				return err
+			}
+			// Unreadable images don't get dimensions during the build either.
+			if image, err := readMediaImage(sourcePath); err == nil {
+				images.images[filepath.ToSlash(relativePath)] = image
+			}
+			return nil
+		})
+	if errors.Is(err, fs.ErrNotExist) {
+		return images, nil
+	}
+	return images, err
+}
+
+// headerKeys returns all keys that may be used in the header of a page.
+func headerKeys() []string {
+	var keys []string
+	for _, field := range reflect.VisibleFields(reflect.TypeOf(ArticleHeaders{})) {
+		if key := field.Tag.Get("yaml"); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (linter *linter) report(file string, line int, format string, arguments ...any) {
+	linter.diagnostics = append(linter.diagnostics, lintDiagnostic{
+		File:    file,
+		Line:    line,
+		Message: fmt.Sprintf(format, arguments...),
+	})
+}
+
+// lintPage checks a single article or custom page. The file is relative to
+// the source directory. Only failing to read the file is returned as an
+// error, everything else is reported as a diagnostic.
+func (linter *linter) lintPage(file string, isArticle bool) error {
+	sourcePath := filepath.Join(linter.sourceDir, filepath.FromSlash(file))
+	pageBytes, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return fmt.Errorf("error reading '%s': %w", file, err)
+	}
+	pageBytes = bytes.ReplaceAll(pageBytes, []byte("\r\n"), []byte("\n"))
+
+	headers, content, err := parsePage(sourcePath, linter.location)
+	if err != nil {
+		linter.report(file, 0, "%s", err)
+		return nil
+	}
+
+	// parsePage already made sure that the header exists.
+	header, _, _ := bytes.Cut(pageBytes, []byte("\n---\n"))
+	linter.lintHeaderKeys(file, header)
+
+	if strings.TrimSpace(headers.Title) == "" {
+		linter.report(file, 0, "title missing")
+	} else {
+		pageLanguage := linter.mainLanguage
+		if headers.Language != "" {
+			pageLanguage, err = parseLanguage(headers.Language)
+			if err != nil {
+				linter.report(file, headerLine(header, "lang"), "%s", err)
+			}
+		}
+		// Translations may share a title, as long as they're written in
+		// different languages.
+		titleKey := pageLanguage + "\n" + strings.ToLower(strings.TrimSpace(headers.Title))
+		if owner, exists := linter.titleOwners[titleKey]; exists {
+			linter.report(file, headerLine(header, "title"), "title '%s' is already used by '%s'", headers.Title, owner)
+		} else {
+			linter.titleOwners[titleKey] = file
+		}
+	}
+	if isArticle && headers.Date == "" {
+		linter.report(file, 0, "date missing")
+	}
+
+	// Markdown is rendered before being checked, so the lines of the
+	// rendered HTML don't match the source.
+	firstLine := 0
+	if !isMarkdownFile(file) {
+		firstLine = bytes.Count(header, []byte("\n")) + 3
+	}
+	problemsBefore := len(linter.diagnostics)
+	linter.lintContent(file, content, firstLine)
+
+	// The transformation fails on the first problem, which the checks above
+	// usually report in more detail already.
+	if _, _, err := transformPageForWeb(content, linter.images); err != nil && len(linter.diagnostics) == problemsBefore {
+		linter.report(file, 0, "%s", err)
+	}
+
+	return nil
+}
+
+// lintHeaderKeys reports all top level header keys, that aren't known and
+// would therefore be silently ignored by the build.
+func (linter *linter) lintHeaderKeys(file string, header []byte) {
+	var values yaml.MapSlice
+	// Syntax errors have already been reported by parsePage.
+	if err := yaml.Unmarshal(header, &values); err != nil {
+		return
+	}
+
+	for _, item := range values {
+		key := fmt.Sprint(item.Key)
+		if slices.Contains(linter.headerKeys, key) {
+			continue
+		}
+
+		message := fmt.Sprintf("unknown header '%s'", key)
+		if suggestion := suggest(key, linter.headerKeys); suggestion != "" {
+			message += fmt.Sprintf(", did you mean '%s'?", suggestion)
+		}
+		linter.report(file, headerLine(header, key), "%s", message)
+	}
+}
+
+// headerLine returns the line on which the given top level key is defined
+// or 0 if it can't be found.
+func headerLine(header []byte, key string) int {
+	for index, line := range strings.Split(string(header), "\n") {
+		if strings.HasPrefix(line, key+":") {
+			return index + 1
+		}
+	}
+	return 0
+}
+
+type openElement struct {
+	name string
+	line int
+}
+
+// lintContent checks the HTML of a page. firstLine is the line of the source
+// file, at which the content starts, or 0 if lines shouldn't be reported.
+func (linter *linter) lintContent(file string, content []byte, firstLine int) {
+	line := firstLine
+	lineOf := func(tokenLine int) int {
+		if firstLine == 0 {
+			return 0
+		}
+		return tokenLine
+	}
+
+	var openElements []openElement
+	// Heading ids are derived the same way as in transformHeading.
+	headingTexts := make(map[string]string)
+	var heading *openElement
+	var headingText string
+	tokenizer := html.NewTokenizer(bytes.NewReader(content))
+	for {
+		tokenType := tokenizer.Next()
+		tokenLine := line
+		line += bytes.Count(tokenizer.Raw(), []byte("\n"))
+		if tokenType == html.ErrorToken {
+			if err := tokenizer.Err(); !errors.Is(err, io.EOF) {
+				linter.report(file, lineOf(tokenLine), "error parsing HTML: %s", err)
+			}
+			break
+		}
+
+		token := tokenizer.Token()
+		switch tokenType {
+		case html.TextToken:
+			if heading != nil {
+				headingText = token.String()
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch token.Data {
+			case "h2", "h3", "h4", "h5", "h6":
+				heading, headingText = &openElement{name: token.Data, line: tokenLine}, ""
+			case "h1":
+				linter.report(file, lineOf(tokenLine), "content headings should be h2 or smaller, as the title of the page is the h1")
+			case "img":
+				src, _ := attr(token, "src")
+				if _, hasAlt := attr(token, "alt"); !hasAlt {
+					linter.report(file, lineOf(tokenLine), "image '%s' has no alt attribute, use alt=\"\" for decorative images", src)
+				}
+				if image, _ := linter.images.lookup(src); image == nil {
+					if _, err := lazyImage(token); err != nil {
+						linter.report(file, lineOf(tokenLine), "image '%s' is set to load lazy, but doesn't have a width and height", src)
+					}
+				}
+			}
+			if tokenType == html.StartTagToken && !voidElements[token.Data] {
+				openElements = append(openElements, openElement{name: token.Data, line: tokenLine})
+			}
+		case html.EndTagToken:
+			if voidElements[token.Data] {
+				continue
+			}
+			if heading != nil && headingText != "" {
+				id := convertToElementId(headingText)
+				text := html.UnescapeString(headingText)
+				if other, exists := headingTexts[id]; exists {
+					linter.report(file, lineOf(heading.line), "headings '%s' and '%s' both have the id '%s'", other, text, id)
+				} else {
+					headingTexts[id] = text
+				}
+			}
+			heading = nil
+			index := len(openElements) - 1
+			for index >= 0 && openElements[index].name != token.Data {
+				index--
+			}
+			if index < 0 {
+				linter.report(file, lineOf(tokenLine), "closing tag '</%s>' has no matching opening tag", token.Data)
+				continue
+			}
+			for _, unclosed := range openElements[index+1:] {
+				if !optionalEndTags[unclosed.name] {
+					linter.report(file, lineOf(unclosed.line), "'<%s>' isn't closed before '</%s>'", unclosed.name, token.Data)
+				}
+			}
+			openElements = openElements[:index]
+		}
+	}
+
+	for _, unclosed := range openElements {
+		if !optionalEndTags[unclosed.name] {
+			linter.report(file, lineOf(unclosed.line), "'<%s>' is never closed", unclosed.name)
+		}
+	}
+}
+
+// reportLintProblems prints all diagnostics and fails if there are any.
+func reportLintProblems(diagnostics []lintDiagnostic) error {
+	for _, diagnostic := range diagnostics {
+		fmt.Fprintln(os.Stderr, diagnostic)
+	}
+	if len(diagnostics) > 0 {
+		return fmt.Errorf("found %d problem(s)", len(diagnostics))
+	}
+	return nil
+}
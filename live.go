@@ -11,7 +11,7 @@ import (
 	"github.com/fsnotify/fsnotify"
 )
 
-func live(sourceDir, basepath, configPath string, port int, minifyOutput, includeDrafts bool) error {
+func live(sourceDir, basepath, configPath string, port int, options BuildOptions) error {
 	// Initial build
 	target := "./.tmp"
 
@@ -20,8 +20,13 @@ func live(sourceDir, basepath, configPath string, port int, minifyOutput, includ
 		return fmt.Errorf("error constructing builder: %w", err)
 	}
 
+	// Browsers are notified about each build, so that they can reload the
+	// page or show the error that occurred.
+	broker := newLiveReloadBroker()
 	build := func() error {
-		return builder.Build(sourceDir, target, configPath, minifyOutput, includeDrafts)
+		err := builder.Build(sourceDir, target, configPath, options)
+		broker.notify(err)
+		return err
 	}
 	if err := build(); err != nil {
 		// We don't return an error here, since the user can simply try
@@ -77,22 +82,30 @@ func live(sourceDir, basepath, configPath string, port int, minifyOutput, includ
 		}
 	}()
 
-	err = filepath.WalkDir(
-		sourceDir,
-		func(path string, dirEntry fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
+	// The theme directory is part of the source directory by default, but
+	// may also be located somewhere else.
+	watchedDirs := []string{sourceDir}
+	if options.ThemeDir != "" {
+		watchedDirs = append(watchedDirs, options.ThemeDir)
+	}
+	for _, watchedDir := range watchedDirs {
+		err = filepath.WalkDir(
+			watchedDir,
+			func(path string, dirEntry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
 
-			if !dirEntry.IsDir() {
-				return nil
-			}
+				if !dirEntry.IsDir() {
+					return nil
+				}
 
-			return watcher.Add(path)
-		})
-	if err != nil {
-		return err
+				return watcher.Add(path)
+			})
+		if err != nil {
+			return err
+		}
 	}
 
-	return serve(target, basepath, port)
+	return serveHandler(newLiveReloadHandler(target, basepath, broker), target, basepath, port)
 }
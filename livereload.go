@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/NYTimes/gziphandler"
+)
+
+// liveReloadPath is the path relative to the basepath, at which the browser
+// receives server-sent events about finished builds.
+const liveReloadPath = ".live-reload"
+
+// liveReloadScript is injected into every HTML page served in dev mode. It
+// reloads the page after each successful build and shows an overlay with
+// the error message if a build fails.
+const liveReloadScript = `<script>
+(function () {
+	const events = new EventSource(%q);
+	events.addEventListener("reload", function () {
+		location.reload();
+	});
+	events.addEventListener("build-error", function (event) {
+		let overlay = document.getElementById("live-reload-error");
+		if (!overlay) {
+			overlay = document.createElement("div");
+			overlay.id = "live-reload-error";
+			overlay.style = "position:fixed;inset:0;z-index:2147483647;overflow:auto;padding:2em;background:rgba(0,0,0,0.9);color:#f66;font-family:monospace;";
+			document.body.appendChild(overlay);
+		}
+		const heading = document.createElement("h2");
+		heading.textContent = "Build failed";
+		const message = document.createElement("pre");
+		message.style = "white-space:pre-wrap;";
+		message.textContent = JSON.parse(event.data);
+		overlay.replaceChildren(heading, message);
+	});
+})();
+</script>`
+
+// liveReloadBroker keeps track of all connected browsers and notifies them
+// about the outcome of each build.
+type liveReloadBroker struct {
+	mutex   sync.Mutex
+	clients map[chan liveReloadEvent]struct{}
+	// buildErr is the error of the last build. It is sent to newly connected
+	// clients, so that reloading the page doesn't hide the error.
+	buildErr error
+}
+
+type liveReloadEvent struct {
+	name string
+	data string
+}
+
+func newLiveReloadBroker() *liveReloadBroker {
+	return &liveReloadBroker{
+		clients: make(map[chan liveReloadEvent]struct{}),
+	}
+}
+
+// notify has to be called after each build, passing the error, if any.
+func (broker *liveReloadBroker) notify(buildErr error) {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	broker.buildErr = buildErr
+	event := liveReloadEvent{name: "reload"}
+	if buildErr != nil {
+		event = newBuildErrorEvent(buildErr)
+	}
+
+	for client := range broker.clients {
+		// Slow clients simply miss events, instead of blocking the build.
+		select {
+		case client <- event:
+		default:
+		}
+	}
+}
+
+func newBuildErrorEvent(buildErr error) liveReloadEvent {
+	// Encoding the message as JSON guarantees it to be on a single line, as
+	// required by the event stream format.
+	data, _ := json.Marshal(buildErr.Error())
+	return liveReloadEvent{name: "build-error", data: string(data)}
+}
+
+func (broker *liveReloadBroker) subscribe() chan liveReloadEvent {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	client := make(chan liveReloadEvent, 1)
+	if broker.buildErr != nil {
+		client <- newBuildErrorEvent(broker.buildErr)
+	}
+	broker.clients[client] = struct{}{}
+	return client
+}
+
+func (broker *liveReloadBroker) unsubscribe(client chan liveReloadEvent) {
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	delete(broker.clients, client)
+}
+
+// ServeHTTP implements [http.Handler] by streaming server-sent events.
+func (broker *liveReloadBroker) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		http.Error(writer, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	client := broker.subscribe()
+	defer broker.unsubscribe(client)
+
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case event := <-client:
+			fmt.Fprintf(writer, "event: %s\ndata: %s\n\n", event.name, event.data)
+			flusher.Flush()
+		}
+	}
+}
+
+// newLiveReloadHandler serves the given directory, injecting the live reload
+// script into each HTML page.
+func newLiveReloadHandler(directoryToServe, basepath string, broker *liveReloadBroker) http.Handler {
+	eventsURL := "/" + liveReloadPath
+	if basepath != "" {
+		eventsURL = "/" + strings.Trim(basepath, "/\\") + eventsURL
+	}
+	script := []byte(fmt.Sprintf(liveReloadScript, eventsURL))
+
+	dir := dirWith404Handler{http.Dir(directoryToServe)}
+	fileServer := http.FileServer(dir)
+	injectingFileServer := gziphandler.GzipHandler(http.HandlerFunc(
+		func(writer http.ResponseWriter, request *http.Request) {
+			// Since pages are constantly rebuilt, nothing should be cached.
+			writer.Header().Set("Cache-Control", "no-store")
+			// Conditional and partial requests would produce responses
+			// that we can't inject into.
+			request.Header.Del("If-Modified-Since")
+			request.Header.Del("Range")
+
+			injectingWriter := &scriptInjectingWriter{ResponseWriter: writer}
+			fileServer.ServeHTTP(injectingWriter, request)
+			injectingWriter.finish(script)
+		}))
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		// The event stream mustn't be compressed, as the gzip handler
+		// buffers the response.
+		if strings.TrimPrefix(request.URL.Path, "/") == liveReloadPath {
+			broker.ServeHTTP(writer, request)
+			return
+		}
+
+		injectingFileServer.ServeHTTP(writer, request)
+	})
+}
+
+// scriptInjectingWriter buffers HTML responses, so that a script can be
+// injected before they are sent. Other responses are passed through.
+type scriptInjectingWriter struct {
+	http.ResponseWriter
+	buffer     bytes.Buffer
+	status     int
+	buffering  bool
+	notFound   bool
+	headerSent bool
+}
+
+func (writer *scriptInjectingWriter) WriteHeader(status int) {
+	if writer.headerSent {
+		return
+	}
+	writer.headerSent = true
+
+	contentType := writer.Header().Get("Content-Type")
+	writer.notFound = status == http.StatusNotFound
+	writer.buffering = writer.notFound || strings.HasPrefix(contentType, "text/html")
+	if !writer.buffering {
+		writer.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	writer.status = status
+	writer.Header().Del("Content-Length")
+}
+
+func (writer *scriptInjectingWriter) Write(data []byte) (int, error) {
+	if !writer.headerSent {
+		writer.WriteHeader(http.StatusOK)
+	}
+	if !writer.buffering {
+		return writer.ResponseWriter.Write(data)
+	}
+	return writer.buffer.Write(data)
+}
+
+func (writer *scriptInjectingWriter) finish(script []byte) {
+	if !writer.buffering {
+		return
+	}
+
+	body := writer.buffer.Bytes()
+	// If the site doesn't even have a 404 page, for example because the
+	// initial build failed, we still need a page to show errors on.
+	if writer.notFound && !strings.HasPrefix(writer.Header().Get("Content-Type"), "text/html") {
+		writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+		body = []byte("<!DOCTYPE html>\n<html><body><h1>404</h1></body></html>")
+	}
+
+	if index := bytes.LastIndex(body, []byte("</body>")); index != -1 {
+		body = append(body[:index:index], append(script, body[index:]...)...)
+	} else {
+		body = append(body, script...)
+	}
+
+	writer.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	writer.ResponseWriter.WriteHeader(writer.status)
+	writer.ResponseWriter.Write(body)
+}
@@ -3,6 +3,10 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
+	// Embedded, so the TimeZone setting also works on systems without a
+	// timezone database, such as Windows.
+	_ "time/tzdata"
 
 	"github.com/spf13/cobra"
 )
@@ -16,7 +20,12 @@ func main() {
 	rootCmd.AddCommand(generateBuildCmd())
 	rootCmd.AddCommand(generateLiveCmd())
 	rootCmd.AddCommand(generateServeCmd())
-	rootCmd.Execute()
+	rootCmd.AddCommand(generateCheckCmd())
+	rootCmd.AddCommand(generateLintCmd())
+	rootCmd.AddCommand(generateNewCmd())
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
 }
 
 func generateLiveCmd() *cobra.Command {
@@ -28,11 +37,22 @@ func generateLiveCmd() *cobra.Command {
 	}
 	minifyOutput := buildCmd.Flags().BoolP("minify", "m", false, "Decides whether css and html files will be minified (reduces file size).")
 	draft := buildCmd.Flags().BoolP("draft", "d", true, "Decides whether draft files are included in the build output.")
-	config := buildCmd.Flags().StringP("config", "c", "", "Defines where the config is. If left empty, the config will be assumed in the source directory.")
+	future := buildCmd.Flags().Bool("future", true, "Decides whether articles dated in the future are included in the build output. These are marked as scheduled.")
+	config := buildCmd.Flags().StringP("config", "c", "", "Defines where the config is. If left empty, the config.json, config.yaml, config.yml or config.toml in the source directory is used.")
 	basepath := buildCmd.Flags().StringP("basepath", "b", "", "Defines the path at which the directory is served. (For example /hello for http://localhost:8080/hello).")
 	port := buildCmd.Flags().IntP("port", "p", 8080, "Decides which port the HTTP server is run on.")
+	theme := buildCmd.Flags().StringP("theme", "t", "", "Defines a directory with templates overriding the default ones. If left empty, the directory 'theme' in the source directory is used, if present.")
+	overrides := buildCmd.Flags().StringArray("set", nil, "Overrides a setting of the config, for example --set URL=https://example.com. Can be repeated.")
 	buildCmd.Run = func(cmd *cobra.Command, args []string) {
-		if err := live(args[0], *basepath, *config, *port, *minifyOutput, *draft); err != nil {
+		options := BuildOptions{
+			MinifyOutput:    *minifyOutput,
+			IncludeDrafts:   *draft,
+			IncludeFuture:   *future,
+			MarkScheduled:   true,
+			ThemeDir:        *theme,
+			ConfigOverrides: *overrides,
+		}
+		if err := live(args[0], *basepath, *config, *port, options); err != nil {
 			log.Println("Error serving files in dev mode:")
 			log.Println(err)
 		}
@@ -51,19 +71,36 @@ func generateBuildCmd() *cobra.Command {
 	}
 	minifyOutput := buildCmd.Flags().BoolP("minify", "m", false, "Decides whether css and html files will be minified (reduces file size).")
 	includeDrafts := buildCmd.Flags().BoolP("draft", "d", false, "Decides whether draft files are included in the build output.")
-	config := buildCmd.Flags().StringP("config", "c", "", "Defines where the config is. If left empty, the config will be assumed in the source directory.")
+	includeFuture := buildCmd.Flags().Bool("future", false, "Decides whether articles dated in the future are included in the build output. Draft builds always include them.")
+	config := buildCmd.Flags().StringP("config", "c", "", "Defines where the config is. If left empty, the config.json, config.yaml, config.yml or config.toml in the source directory is used.")
 	output := buildCmd.Flags().StringP("output", "o", "output", "Defines the directory where the build result will be written to.")
+	clean := buildCmd.Flags().Bool("clean", false, "Ignores the results of previous builds and regenerates all files.")
+	theme := buildCmd.Flags().StringP("theme", "t", "", "Defines a directory with templates overriding the default ones. If left empty, the directory 'theme' in the source directory is used, if present.")
+	checkLinks := buildCmd.Flags().Bool("check-links", false, "Verifies all internal links after building and fails if any of them are broken.")
+	overrides := buildCmd.Flags().StringArray("set", nil, "Overrides a setting of the config, for example --set URL=https://example.com. Can be repeated.")
 	buildCmd.RunE = func(cmd *cobra.Command, args []string) error {
 		source := args[0]
 		if source == *output {
 			return fmt.Errorf("source and output can't be the same")
 		}
+		// The arguments are valid, so the usage would only hide the actual
+		// problem.
+		cmd.SilenceUsage = true
 
 		builder, err := NewBuilder()
 		if err != nil {
 			return fmt.Errorf("error constructing builder: %w", err)
 		}
-		if err := builder.Build(source, *output, *config, *minifyOutput, *includeDrafts); err != nil {
+		options := BuildOptions{
+			MinifyOutput:    *minifyOutput,
+			IncludeDrafts:   *includeDrafts,
+			IncludeFuture:   *includeFuture,
+			Clean:           *clean,
+			ThemeDir:        *theme,
+			CheckLinks:      *checkLinks,
+			ConfigOverrides: *overrides,
+		}
+		if err := builder.Build(source, *output, *config, options); err != nil {
 			return fmt.Errorf("error executing build: %w", err)
 		}
 		return nil
@@ -90,3 +127,105 @@ func generateServeCmd() *cobra.Command {
 
 	return serveCmd
 }
+
+func generateCheckCmd() *cobra.Command {
+	checkCmd := &cobra.Command{
+		Use:     "check directory",
+		Short:   "Verifies that all internal links of a built site point to existing files and anchors.",
+		Example: "check ./output --basepath /blog",
+		Args:    cobra.ExactArgs(1),
+	}
+	basepath := checkCmd.Flags().StringP("basepath", "b", "", "Defines the path at which the site is served. (For example /hello for http://localhost:8080/hello).")
+	url := checkCmd.Flags().StringP("url", "u", "", "Defines the URL of the site. If set, absolute links to the site, such as in feeds, are checked as well.")
+	checkCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		// The arguments are valid, so the usage would only hide the actual
+		// problem.
+		cmd.SilenceUsage = true
+		diagnostics, err := checkLinks(args[0], *basepath, *url)
+		if err != nil {
+			return fmt.Errorf("error checking links: %w", err)
+		}
+		return reportBrokenLinks(diagnostics)
+	}
+
+	return checkCmd
+}
+
+func generateLintCmd() *cobra.Command {
+	lintCmd := &cobra.Command{
+		Use:        "lint directory",
+		Short:      "Checks all articles and pages of the source directory and reports all problems at once.",
+		Example:    "lint ./example",
+		SuggestFor: []string{"validate", "verify"},
+		Args:       cobra.ExactArgs(1),
+	}
+	config := lintCmd.Flags().StringP("config", "c", "", "Defines where the config is. If left empty, the config.json, config.yaml, config.yml or config.toml in the source directory is used.")
+	overrides := lintCmd.Flags().StringArray("set", nil, "Overrides a setting of the config, for example --set URL=https://example.com. Can be repeated.")
+	lintCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		// The arguments are valid, so the usage would only hide the actual
+		// problem.
+		cmd.SilenceUsage = true
+		diagnostics, err := lintSource(args[0], *config, *overrides)
+		if err != nil {
+			return fmt.Errorf("error linting source: %w", err)
+		}
+		return reportLintProblems(diagnostics)
+	}
+
+	return lintCmd
+}
+
+func generateNewCmd() *cobra.Command {
+	newCmd := &cobra.Command{
+		Use:   "new",
+		Short: "Creates a new site, article or page.",
+	}
+
+	siteCmd := &cobra.Command{
+		Use:     "site directory",
+		Short:   "Creates the directory structure and config of a new site.",
+		Example: "new site ./blog --name \"My Blog\" --author \"Firstname Lastname\" --url https://github-handle.github.io",
+		Args:    cobra.ExactArgs(1),
+	}
+	var settings siteSettings
+	siteCmd.Flags().StringVar(&settings.SiteName, "name", "My Blog", "Defines the name of the site.")
+	siteCmd.Flags().StringVar(&settings.Author, "author", "", "Defines the author, used for metadata and feeds.")
+	siteCmd.Flags().StringVar(&settings.Email, "email", "", "Defines the email address of the author, used for feeds.")
+	siteCmd.Flags().StringVar(&settings.Description, "description", "", "Defines the description of the site, used for metadata and feeds.")
+	siteCmd.Flags().StringVar(&settings.URL, "url", "", "Defines the URL the site is served at, for example https://github-handle.github.io.")
+	siteCmd.Flags().StringVarP(&settings.BasePath, "basepath", "b", "", "Defines the path at which the site is served. (For example /hello for http://localhost:8080/hello).")
+	siteCmd.Flags().StringVar(&settings.Language, "language", "", "Defines the language of the site, for example de or en-GB.")
+	format := siteCmd.Flags().String("format", "json", "Decides the format of the config, either json, yaml or toml.")
+	siteCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if err := createSite(args[0], *format, settings); err != nil {
+			return fmt.Errorf("error creating site: %w", err)
+		}
+		fmt.Printf("Created site in '%s'.\n", args[0])
+		return nil
+	}
+	newCmd.AddCommand(siteCmd)
+
+	for _, kind := range []struct{ name, dir string }{{"article", "articles"}, {"page", "pages"}} {
+		pageCmd := &cobra.Command{
+			Use:     kind.name + " title",
+			Short:   fmt.Sprintf("Creates a draft %s with the given title.", kind.name),
+			Example: fmt.Sprintf("new %s \"My first %s\" --dir ./blog", kind.name, kind.name),
+			Args:    cobra.ExactArgs(1),
+		}
+		sourceDir := pageCmd.Flags().StringP("dir", "C", ".", "Defines the source directory of the site.")
+		markdown := pageCmd.Flags().Bool("markdown", false, "Decides whether the file is written in Markdown instead of HTML.")
+		pageCmd.RunE = func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			pagePath, err := createPage(*sourceDir, kind.dir, args[0], *markdown)
+			if err != nil {
+				return fmt.Errorf("error creating %s: %w", kind.name, err)
+			}
+			fmt.Printf("Created %s '%s'.\n", kind.name, pagePath)
+			return nil
+		}
+		newCmd.AddCommand(pageCmd)
+	}
+
+	return newCmd
+}
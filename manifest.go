@@ -0,0 +1,194 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+)
+
+// manifestName is the name of the file in the output directory, which
+// records the inputs that were used to generate the output of the last build.
+const manifestName = ".stasi-blog-manifest.json"
+
+// buildManifest maps each generated output file to a hash of all inputs that
+// were used to generate it. If the hash of an output didn't change since the
+// last build, there's no need to generate it again.
+type buildManifest struct {
+	outputDir string
+	// previous contains the hashes of the last successful build. If nil,
+	// either there was no previous build or a clean build was requested.
+	previous map[string]string
+	current  map[string]string
+}
+
+func loadBuildManifest(outputDir string) (*buildManifest, error) {
+	manifest := &buildManifest{
+		outputDir: outputDir,
+		current:   make(map[string]string),
+	}
+
+	manifestFile, err := os.Open(filepath.Join(outputDir, manifestName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return manifest, nil
+		}
+		return nil, err
+	}
+	defer manifestFile.Close()
+
+	if err := json.NewDecoder(manifestFile).Decode(&manifest.previous); err != nil {
+		return nil, fmt.Errorf("error decoding build manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// hasPrevious indicates whether there's anything to compare against. If
+// not, all files will be generated.
+func (manifest *buildManifest) hasPrevious() bool {
+	return manifest.previous != nil
+}
+
+// discardPrevious forces all files to be regenerated. Files generated by the
+// previous build are deleted first, as cleanup only knows about files in
+// fixed locations, but not for example about the folders of languages.
+func (manifest *buildManifest) discardPrevious() error {
+	for file := range manifest.previous {
+		if err := manifest.remove(file); err != nil {
+			return err
+		}
+	}
+	manifest.previous = nil
+	return nil
+}
+
+// upToDate records the hash for the given output file and returns whether
+// the file still exists and was generated from the same inputs during the
+// last build. If false is returned, the caller has to (re)generate the file.
+func (manifest *buildManifest) upToDate(file, hash string) bool {
+	file = filepath.ToSlash(file)
+	manifest.current[file] = hash
+
+	previousHash, exists := manifest.previous[file]
+	if !exists || previousHash != hash {
+		return false
+	}
+
+	_, err := os.Stat(filepath.Join(manifest.outputDir, file))
+	return err == nil
+}
+
+// removeStale deletes all files that were generated by the previous build,
+// but not by the current one. For example, because an article was deleted.
+func (manifest *buildManifest) removeStale() error {
+	for file := range manifest.previous {
+		if _, exists := manifest.current[file]; exists {
+			continue
+		}
+
+		if err := manifest.remove(file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// remove deletes a generated file. Folders left empty, such as the folder of
+// a language that isn't used anymore, are deleted as well.
+func (manifest *buildManifest) remove(file string) error {
+	err := os.Remove(filepath.Join(manifest.outputDir, filepath.FromSlash(file)))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	for dir := path.Dir(file); dir != "."; dir = path.Dir(dir) {
+		// Fails for folders that aren't empty, which is fine.
+		if os.Remove(filepath.Join(manifest.outputDir, filepath.FromSlash(dir))) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (manifest *buildManifest) save() error {
+	manifestFile, err := createFile(filepath.Join(manifest.outputDir, manifestName))
+	if err != nil {
+		return err
+	}
+	defer manifestFile.Close()
+
+	encoder := json.NewEncoder(manifestFile)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(manifest.current)
+}
+
+// generatorHash identifies the running version of stasi-blog. Output
+// generated by a different version has to be regenerated, as the
+// transformations applied to the content might have changed. The executable
+// is hashed, so that this also works for local builds, which don't carry a
+// version. If it can't be read, the build info is used instead.
+func generatorHash() string {
+	if executable, err := os.Executable(); err == nil {
+		if hash, err := hashFile(executable); err == nil {
+			return hash
+		}
+	}
+
+	buildInfo, _ := debug.ReadBuildInfo()
+	return hashInputs(buildInfo)
+}
+
+// hashInputs produces a hash over all given inputs. Inputs are serialised
+// as JSON, therefore unexported fields aren't taken into account.
+func hashInputs(inputs ...any) string {
+	hash := sha256.New()
+	encoder := json.NewEncoder(hash)
+	for _, input := range inputs {
+		// Our inputs are plain data, so encoding can't fail.
+		_ = encoder.Encode(input)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func hashFS(fileSystem fs.FS, root string) (string, error) {
+	hash := sha256.New()
+	err := fs.WalkDir(fileSystem, root, func(path string, dirEntry fs.DirEntry, err error) error {
+		if err != nil || dirEntry.IsDir() {
+			return err
+		}
+
+		data, err := fs.ReadFile(fileSystem, path)
+		if err != nil {
+			return err
+		}
+		hash.Write([]byte(path))
+		hash.Write(data)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
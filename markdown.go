@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		extension.Footnote,
+	),
+	goldmark.WithRendererOptions(
+		// Raw HTML has to be kept, so that authors can still use things
+		// such as <asciicast> or images with custom attributes.
+		html.WithUnsafe(),
+	),
+)
+
+// isMarkdownFile decides whether a source file has to be rendered from
+// Markdown to HTML before being processed any further.
+func isMarkdownFile(name string) bool {
+	return filepath.Ext(name) == ".md"
+}
+
+// isPageFile decides whether a file in the articles or pages directory is a
+// source file. Other files are ignored. For example I use this to create
+// .html-draft files which are posts that I don't want to publish yet, but
+// still have in the blog source directory.
+func isPageFile(name string) bool {
+	return filepath.Ext(name) == ".html" || isMarkdownFile(name)
+}
+
+// outputFileName returns the name of the HTML file generated from the given
+// source file.
+func outputFileName(name string) string {
+	return name[:len(name)-len(filepath.Ext(name))] + ".html"
+}
+
+// renderMarkdown converts CommonMark (plus GFM tables and footnotes) into
+// HTML, which can then be treated like any handwritten HTML content.
+func renderMarkdown(source []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := markdown.Convert(source, &buffer); err != nil {
+		return nil, err
+	}
+
+	// Link destinations are URL-escaped, which breaks template actions such
+	// as `{{.BasePath}}/media/image.png`, so we restore the delimiters.
+	rendered := bytes.ReplaceAll(buffer.Bytes(), []byte("%7B%7B"), []byte("{{"))
+	rendered = bytes.ReplaceAll(rendered, []byte("%7D%7D"), []byte("}}"))
+	return rendered, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/goccy/go-yaml"
+)
+
+// siteSettings are the settings written into the config of a new site.
+// Empty settings are left out, so that the defaults apply.
+type siteSettings struct {
+	SiteName            string `json:",omitempty" yaml:"SiteName,omitempty" toml:"SiteName,omitempty"`
+	Author              string `json:",omitempty" yaml:"Author,omitempty" toml:"Author,omitempty"`
+	Email               string `json:",omitempty" yaml:"Email,omitempty" toml:"Email,omitempty"`
+	Description         string `json:",omitempty" yaml:"Description,omitempty" toml:"Description,omitempty"`
+	URL                 string `json:",omitempty" yaml:"URL,omitempty" toml:"URL,omitempty"`
+	BasePath            string `json:",omitempty" yaml:"BasePath,omitempty" toml:"BasePath,omitempty"`
+	Language            string `json:",omitempty" yaml:"Language,omitempty" toml:"Language,omitempty"`
+	CreationDate        string `yaml:"CreationDate" toml:"CreationDate"`
+	DateFormat          string `yaml:"DateFormat" toml:"DateFormat"`
+	MaxIndexEntries     int    `yaml:"MaxIndexEntries" toml:"MaxIndexEntries"`
+	AddOptionalMetaData bool   `yaml:"AddOptionalMetaData" toml:"AddOptionalMetaData"`
+}
+
+// createSite creates the directory structure expected by the build and a
+// config in the given format, which is either json, yaml or toml. Existing
+// sites aren't touched.
+func createSite(dir, format string, settings siteSettings) error {
+	for _, name := range configNames {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return fmt.Errorf("'%s' already contains a site", dir)
+		}
+	}
+
+	settings.CreationDate = time.Now().Format(time.RFC3339)
+	settings.DateFormat = defaultDateFormat
+	settings.MaxIndexEntries = defaultMaxIndexEntries
+	settings.AddOptionalMetaData = true
+
+	var data []byte
+	var err error
+	switch format {
+	case "json":
+		data, err = json.MarshalIndent(settings, "", "  ")
+		data = append(data, '\n')
+	case "yaml":
+		data, err = yaml.Marshal(settings)
+	case "toml":
+		var buffer bytes.Buffer
+		err = toml.NewEncoder(&buffer).Encode(settings)
+		data = buffer.Bytes()
+	default:
+		return fmt.Errorf("unknown config format '%s', use json, yaml or toml", format)
+	}
+	if err != nil {
+		return fmt.Errorf("error generating config: %w", err)
+	}
+
+	// Catches invalid settings, such as a URL without a scheme, before
+	// anything is written.
+	config := defaultConfig()
+	problems, err := decodeConfig(data, "."+format, &config)
+	if err != nil {
+		return err
+	}
+	if err := errors.Join(append(problems, config.validate())...); err != nil {
+		return fmt.Errorf("invalid settings:\n%w", err)
+	}
+
+	for _, subDir := range []string{"articles", "pages", "media"} {
+		if err := createDirectories(filepath.Join(dir, subDir)); err != nil {
+			return fmt.Errorf("error creating directory '%s': %w", subDir, err)
+		}
+	}
+	return writeNewFile(filepath.Join(dir, "config."+format), data)
+}
+
+// createPage creates a draft article or custom page in the given directory
+// of the site, for example "articles". The file name is derived from the
+// title. The path of the new file is returned.
+func createPage(sourceDir, subDir, title string, markdown bool) (string, error) {
+	configPath, err := findConfig(sourceDir)
+	if err != nil {
+		return "", fmt.Errorf("'%s' doesn't seem to be a site: %w", sourceDir, err)
+	}
+	config, err := loadConfig(configPath, nil)
+	if err != nil {
+		return "", err
+	}
+
+	// Marshalling takes care of quoting titles such as "Go: A Tour".
+	quotedTitle, err := yaml.Marshal(title)
+	if err != nil {
+		return "", fmt.Errorf("error generating headers: %w", err)
+	}
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "title: %s", quotedTitle)
+	if subDir == "articles" {
+		location := time.UTC
+		if config.TimeZone != "" {
+			location, err = time.LoadLocation(config.TimeZone)
+			if err != nil {
+				return "", fmt.Errorf("error loading TimeZone '%s': %w", config.TimeZone, err)
+			}
+		}
+		fmt.Fprintf(&content, "description: \"\"\ndate: %s\ntags: []\n", time.Now().In(location).Format("2006-01-02"))
+	}
+	content.WriteString("draft: true\n---\n")
+
+	extension := ".html"
+	if markdown {
+		extension = ".md"
+	} else {
+		content.WriteString("<p></p>\n")
+	}
+	pagePath := filepath.Join(sourceDir, subDir, slugify(title, config.Slugs)+extension)
+	if err := writeNewFile(pagePath, content.Bytes()); err != nil {
+		return "", err
+	}
+	return pagePath, nil
+}
+
+// writeNewFile writes the file, failing if it already exists.
+func writeNewFile(filePath string, data []byte) error {
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("'%s' already exists", filePath)
+		}
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,75 @@
+package main
+
+import "sort"
+
+// defaultMaxRelatedArticles is used unless MaxRelatedArticles is configured.
+const defaultMaxRelatedArticles = 3
+
+// articleLink is the minimal information required to link to an article.
+type articleLink struct {
+	Title     string
+	File      string
+	HumanTime string
+}
+
+func newArticleLink(article *indexedArticle) *articleLink {
+	return &articleLink{
+		Title:     article.Title,
+		File:      article.File,
+		HumanTime: article.HumanTime,
+	}
+}
+
+// relatedArticles ranks all articles by the number of tags they share with
+// the given article. Newer articles win ties. Articles without any shared
+// tags aren't considered related, neither are translations of the article.
+func relatedArticles(article *indexedArticle, articles []*indexedArticle, max int) []articleLink {
+	if max <= 0 || len(article.Tags) == 0 {
+		return nil
+	}
+
+	tags := make(map[string]struct{}, len(article.Tags))
+	for _, tag := range article.Tags {
+		tags[tag] = struct{}{}
+	}
+
+	type candidate struct {
+		article    *indexedArticle
+		sharedTags int
+		// position is the index in the list of articles, which is sorted by
+		// date, newest first.
+		position int
+	}
+	var candidates []candidate
+	for position, other := range articles {
+		if other == article || (article.translationKey != "" && other.translationKey == article.translationKey) {
+			continue
+		}
+
+		var sharedTags int
+		for _, tag := range other.Tags {
+			if _, shared := tags[tag]; shared {
+				sharedTags++
+			}
+		}
+		if sharedTags > 0 {
+			candidates = append(candidates, candidate{other, sharedTags, position})
+		}
+	}
+
+	sort.Slice(candidates, func(a, b int) bool {
+		if candidates[a].sharedTags != candidates[b].sharedTags {
+			return candidates[a].sharedTags > candidates[b].sharedTags
+		}
+		return candidates[a].position < candidates[b].position
+	})
+
+	if len(candidates) > max {
+		candidates = candidates[:max]
+	}
+	links := make([]articleLink, 0, len(candidates))
+	for _, candidate := range candidates {
+		links = append(links, *newArticleLink(candidate.article))
+	}
+	return links
+}
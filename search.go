@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// searchIndexEntry is an article, as it is consumed by search.js.
+type searchIndexEntry struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	URL         string   `json:"url"`
+	Text        string   `json:"text"`
+}
+
+// inlineElements are elements that don't separate words.
+var inlineElements = map[string]bool{
+	"a": true, "abbr": true, "b": true, "code": true, "em": true, "i": true,
+	"kbd": true, "mark": true, "s": true, "small": true, "span": true,
+	"strong": true, "sub": true, "sup": true, "u": true,
+}
+
+// extractText strips all markup from the given HTML, leaving only the text
+// a reader would see. Whitespace is collapsed to keep the search index small.
+func extractText(content []byte) (string, error) {
+	var text strings.Builder
+	tokenizer := html.NewTokenizer(bytes.NewReader(content))
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); !errors.Is(err, io.EOF) {
+				return "", err
+			}
+			return strings.Join(strings.Fields(text.String()), " "), nil
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			name, _ := tokenizer.TagName()
+			// Scripts and styles are text tokens, but aren't visible.
+			if tokenType == html.StartTagToken && (string(name) == "script" || string(name) == "style") {
+				tokenizer.Next()
+			}
+			// Words in separate blocks mustn't be glued together.
+			if !inlineElements[string(name)] {
+				text.WriteByte(' ')
+			}
+		case html.TextToken:
+			text.Write(tokenizer.Text())
+		}
+	}
+}
+
+// writeSearch writes the search page, the script powering it and the index
+// of all given articles.
+func writeSearch(
+	theme *theme,
+	articles []*indexedArticle,
+	data *customPageData,
+	outputDir string,
+	minifyOutput bool,
+	manifest *buildManifest,
+	sharedHash string,
+) error {
+	index := make([]searchIndexEntry, 0, len(articles))
+	for _, article := range articles {
+		index = append(index, searchIndexEntry{
+			Title:       article.Title,
+			Description: article.Description,
+			Tags:        article.Tags,
+			URL:         article.File,
+			Text:        article.searchText,
+		})
+	}
+	indexData, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("couldn't generate search index: %w", err)
+	}
+	if err := writeFileIfChanged(outputDir, "search-index.json", string(indexData), manifest); err != nil {
+		return fmt.Errorf("couldn't write search index: %w", err)
+	}
+
+	if !manifest.upToDate("search.js", theme.hash) {
+		script, err := theme.openAsset("search.js")
+		if err != nil {
+			return fmt.Errorf("couldn't read search.js: %w", err)
+		}
+		defer script.Close()
+
+		if err := copyDataIntoFile(script, filepath.Join(outputDir, "search.js")); err != nil {
+			return err
+		}
+	}
+
+	if manifest.upToDate("search.html", sharedHash) {
+		return nil
+	}
+	return writeTemplateToFile(theme.templates.Lookup("search"), data, outputDir, "search.html", minifyOutput)
+}
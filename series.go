@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+)
+
+// seriesHeader can either be written as `series: Name` or, to define the
+// position of the article within the series, as
+// `series: {name: Name, order: 2}`.
+type seriesHeader struct {
+	Name string `yaml:"name"`
+	// Order defaults to 0. Parts with the same order are sorted by date.
+	Order int `yaml:"order"`
+}
+
+func (header *seriesHeader) UnmarshalYAML(unmarshal func(any) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		header.Name = name
+		return nil
+	}
+
+	// Prevents endless recursion, as plain doesn't implement the unmarshaler.
+	type plain seriesHeader
+	return unmarshal((*plain)(header))
+}
+
+// articleSeries groups multiple articles, which are meant to be read in
+// order, such as a multi-part tutorial.
+type articleSeries struct {
+	Name string
+	// File is the overview page listing all parts.
+	File string
+	// Parts are sorted in reading order.
+	Parts   []*indexedArticle
+	members []*seriesMember
+}
+
+type seriesMember struct {
+	name    string
+	order   int
+	article *indexedArticle
+	// data receives the navigation, once all parts are known.
+	data *articlePageData
+}
+
+// seriesNavigation is shown on each article that is part of a series.
+type seriesNavigation struct {
+	Name string
+	// File is the overview page listing all parts.
+	File  string
+	Parts []seriesPart
+	// Part is the position of the current article, starting at 1.
+	Part     int
+	Previous *seriesPart
+	Next     *seriesPart
+}
+
+type seriesPart struct {
+	Title   string
+	File    string
+	Current bool
+}
+
+type seriesPageData struct {
+	blogConfig
+	CustomPages []*customPageEntry
+	Series      *articleSeries
+}
+
+// seriesFile is the overview page of a series, relative to the output
+// directory.
+func seriesFile(name string, config slugConfig) string {
+	return path.Join("series", slugify(name, config)+".html")
+}
+
+// groupSeries collects all articles with a series header, sorts the parts
+// of each series and adds the navigation to the data of each part.
+func groupSeries(members []*seriesMember, slugs slugConfig) ([]*articleSeries, error) {
+	seriesByName := make(map[string]*articleSeries)
+	seriesByFile := make(map[string]*articleSeries)
+	var allSeries []*articleSeries
+	for _, member := range members {
+		series, exists := seriesByName[member.name]
+		if !exists {
+			series = &articleSeries{Name: member.name, File: seriesFile(member.name, slugs)}
+			if other, clashes := seriesByFile[series.File]; clashes {
+				return nil, fmt.Errorf("series '%s' and '%s' would both be written to '%s', rename one of them", other.Name, series.Name, series.File)
+			}
+			seriesByFile[series.File] = series
+			seriesByName[member.name] = series
+			allSeries = append(allSeries, series)
+		}
+		series.members = append(series.members, member)
+	}
+
+	sort.Slice(allSeries, func(a, b int) bool {
+		return allSeries[a].Name < allSeries[b].Name
+	})
+	for _, series := range allSeries {
+		sort.Slice(series.members, func(a, b int) bool {
+			partA, partB := series.members[a], series.members[b]
+			if partA.order != partB.order {
+				return partA.order < partB.order
+			}
+			if !partA.article.RFC3339Time.Equal(partB.article.RFC3339Time) {
+				return partA.article.RFC3339Time.Before(partB.article.RFC3339Time)
+			}
+			return partA.article.File < partB.article.File
+		})
+
+		for _, member := range series.members {
+			series.Parts = append(series.Parts, member.article)
+		}
+
+		for current, member := range series.members {
+			navigation := &seriesNavigation{
+				Name: series.Name,
+				File: series.File,
+				Part: current + 1,
+			}
+			for index, part := range series.Parts {
+				navigation.Parts = append(navigation.Parts, seriesPart{
+					Title:   part.Title,
+					File:    part.File,
+					Current: index == current,
+				})
+			}
+			if current > 0 {
+				navigation.Previous = &navigation.Parts[current-1]
+			}
+			if current < len(series.members)-1 {
+				navigation.Next = &navigation.Parts[current+1]
+			}
+			member.data.Series = navigation
+		}
+	}
+
+	return allSeries, nil
+}
+
+// writeSeriesPages writes an overview page for each series, listing all of
+// its parts in order.
+func writeSeriesPages(
+	theme *theme,
+	allSeries []*articleSeries,
+	data seriesPageData,
+	outputDir string,
+	minifyOutput bool,
+	manifest *buildManifest,
+	sharedHash string,
+) error {
+	seriesTemplate := theme.templates.Lookup("series")
+	for _, series := range allSeries {
+		data.Series = series
+		data.Title = series.Name
+		if manifest.upToDate(series.File, hashInputs(sharedHash, series)) {
+			continue
+		}
+
+		if err := writeTemplateToFile(seriesTemplate, data, outputDir, series.File, minifyOutput); err != nil {
+			return fmt.Errorf("error writing series '%s': %w", series.Name, err)
+		}
+	}
+
+	return nil
+}
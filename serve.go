@@ -16,6 +16,14 @@ func serve(directoryToServe, basepath string, port int) error {
 	// Example in my case.
 	// go run . --input="../blog-test-source" --output="../blog-test" & go run demo/server.go --dir="../blog-test" --basepath="/blog-test/"
 
+	dir := dirWith404Handler{http.Dir(directoryToServe)}
+	handler := gziphandler.GzipHandler(http.FileServer(dir))
+	return serveHandler(handler, directoryToServe, basepath, port)
+}
+
+// serveHandler serves the given handler at the basepath until the process
+// is terminated.
+func serveHandler(handler http.Handler, directoryToServe, basepath string, port int) error {
 	go func() {
 		sc := make(chan os.Signal, 1)
 		signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM)
@@ -27,8 +35,6 @@ func serve(directoryToServe, basepath string, port int) error {
 	log.Println("Please remember to only use this command to serve your website in a development scenario.")
 	portString := fmt.Sprintf("localhost:%d", port)
 
-	dir := dirWith404Handler{http.Dir(directoryToServe)}
-	handler := gziphandler.GzipHandler(http.FileServer(dir))
 	if basepath == "" {
 		return http.ListenAndServe(portString, handler)
 	}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+// sitemapEntry is a page that is to be listed in the sitemap.
+type sitemapEntry struct {
+	// File is the path relative to the output directory.
+	File string
+	// LastModified is optional.
+	LastModified time.Time
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Location     string `xml:"loc"`
+	LastModified string `xml:"lastmod,omitempty"`
+}
+
+// writeSitemap writes a sitemap.xml containing all given entries and a
+// robots.txt pointing to it. Both require absolute URLs, so this only works
+// if an URL has been configured.
+func writeSitemap(
+	outputFolder string,
+	entries []sitemapEntry,
+	loadedPageConfig blogConfig,
+	manifest *buildManifest,
+) error {
+	urlSet := sitemapURLSet{URLs: make([]sitemapURL, 0, len(entries))}
+	for _, entry := range entries {
+		location, err := absoluteURL(loadedPageConfig, entry.File)
+		if err != nil {
+			return fmt.Errorf("couldn't generate URL for '%s': %w", entry.File, err)
+		}
+
+		url := sitemapURL{Location: location}
+		if !entry.LastModified.IsZero() {
+			url.LastModified = entry.LastModified.Format(time.RFC3339)
+		}
+		urlSet.URLs = append(urlSet.URLs, url)
+	}
+
+	sitemapData, err := xml.MarshalIndent(urlSet, "", "  ")
+	if err != nil {
+		return fmt.Errorf("couldn't generate sitemap: %w", err)
+	}
+	if err := writeFileIfChanged(outputFolder, "sitemap.xml", xml.Header+string(sitemapData)+"\n", manifest); err != nil {
+		return fmt.Errorf("couldn't write sitemap: %w", err)
+	}
+
+	sitemapURL, err := absoluteURL(loadedPageConfig, "sitemap.xml")
+	if err != nil {
+		return fmt.Errorf("couldn't generate sitemap URL: %w", err)
+	}
+	// Note that crawlers only respect the robots.txt at the domain root,
+	// so if a BasePath is used, this file has to be moved manually.
+	robotsData := fmt.Sprintf("User-agent: *\nAllow: /\n\nSitemap: %s\n", sitemapURL)
+	if err := writeFileIfChanged(outputFolder, "robots.txt", robotsData, manifest); err != nil {
+		return fmt.Errorf("couldn't write robots.txt: %w", err)
+	}
+
+	return nil
+}
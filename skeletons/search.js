@@ -0,0 +1,94 @@
+// Searches the articles listed in search-index.json, which is generated
+// during the build. All articles containing every term of the query are
+// shown, ordered by where the terms were found.
+(function () {
+    const form = document.querySelector(".search-form");
+    const input = form.querySelector("input");
+    const results = document.querySelector(".search-results");
+    const basePath = form.dataset.basePath;
+    let articles = null;
+
+    function score(article, term) {
+        let score = 0;
+        if (article.title.toLowerCase().includes(term)) {
+            score += 10;
+        }
+        if (article.tags && article.tags.some((tag) => tag.includes(term))) {
+            score += 5;
+        }
+        if (article.description && article.description.toLowerCase().includes(term)) {
+            score += 3;
+        }
+        if (article.text.toLowerCase().includes(term)) {
+            score += 1;
+        }
+        return score;
+    }
+
+    function render(query) {
+        const terms = query.toLowerCase().split(/\s+/).filter((term) => term);
+        const matches = [];
+        for (const article of terms.length ? articles : []) {
+            let total = 0;
+            for (const term of terms) {
+                const termScore = score(article, term);
+                if (termScore === 0) {
+                    total = 0;
+                    break;
+                }
+                total += termScore;
+            }
+            if (total > 0) {
+                matches.push({ article, total });
+            }
+        }
+        matches.sort((a, b) => b.total - a.total);
+
+        results.replaceChildren(...matches.map(({ article }) => {
+            const entry = document.createElement("div");
+            const link = document.createElement("a");
+            link.href = basePath + "/" + article.url;
+            link.textContent = article.title;
+            entry.appendChild(link);
+            if (article.description) {
+                const description = document.createElement("p");
+                description.textContent = article.description;
+                entry.appendChild(description);
+            }
+            return entry;
+        }));
+        if (terms.length && !matches.length) {
+            results.textContent = form.dataset.noResults;
+        }
+    }
+
+    function search() {
+        const query = input.value;
+        const url = new URL(location);
+        url.searchParams.set("q", query);
+        history.replaceState(null, "", url);
+
+        if (articles) {
+            render(query);
+            return;
+        }
+        fetch(form.dataset.index)
+            .then((response) => response.json())
+            .then((index) => {
+                articles = index;
+                render(input.value);
+            });
+    }
+
+    form.addEventListener("submit", (event) => {
+        event.preventDefault();
+        search();
+    });
+    input.addEventListener("input", search);
+
+    const query = new URLSearchParams(location.search).get("q");
+    if (query) {
+        input.value = query;
+        search();
+    }
+})();
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// slugConfig defines how names are turned into file names.
+type slugConfig struct {
+	// Separator replaces whitespace and punctuation. It may only consist of
+	// "-", "_" and ".". Defaults to "-".
+	Separator string
+	// KeepUnicode keeps letters outside of ASCII, instead of transliterating
+	// or dropping them. Modern browsers display these just fine, but some
+	// servers and tools have trouble with them.
+	KeepUnicode bool
+}
+
+// transliterations covers letters that unicode normalisation can't reduce
+// to ASCII.
+var transliterations = map[rune]string{
+	'ß': "ss",
+	'æ': "ae",
+	'œ': "oe",
+	'ø': "o",
+	'đ': "d",
+	'ð': "d",
+	'ł': "l",
+	'þ': "th",
+	'ı': "i",
+	'ŋ': "ng",
+}
+
+// slugify turns any name into a string that is safe to use as file name and
+// in URLs. Characters such as `/` or `..` can never be part of the result.
+// If nothing usable is left, for example because a name only consists of
+// characters that can't be transliterated, a hash is used instead.
+func slugify(name string, config slugConfig) string {
+	separator := config.Separator
+	if separator == "" || strings.Trim(separator, "-_.") != "" {
+		separator = "-"
+	}
+
+	var slug strings.Builder
+	pendingSeparator := false
+	writeRune := func(char rune) {
+		if pendingSeparator && slug.Len() > 0 {
+			slug.WriteString(separator)
+		}
+		pendingSeparator = false
+		slug.WriteRune(char)
+	}
+
+	// Decomposition splits letters such as `é` into `e` and a combining
+	// accent, which is then dropped.
+	for _, char := range norm.NFKD.String(strings.ToLower(name)) {
+		switch {
+		case char < unicode.MaxASCII && (unicode.IsLetter(char) || unicode.IsDigit(char)):
+			writeRune(char)
+		case unicode.Is(unicode.Mn, char):
+			// Combining marks belong to the previous letter and are only
+			// kept if unicode is kept in general.
+			if config.KeepUnicode && slug.Len() > 0 && !pendingSeparator {
+				slug.WriteRune(char)
+			}
+		case transliterations[char] != "" && !config.KeepUnicode:
+			for _, replacement := range transliterations[char] {
+				writeRune(replacement)
+			}
+		case config.KeepUnicode && (unicode.IsLetter(char) || unicode.IsDigit(char)):
+			writeRune(char)
+		default:
+			pendingSeparator = true
+		}
+	}
+
+	if slug.Len() == 0 {
+		return slugHash(name)
+	}
+
+	// With KeepUnicode, the decomposed form would end up in the file name,
+	// which differs from what most people type.
+	return norm.NFC.String(slug.String())
+}
+
+// slugHash is a short hash of the name, used where the name itself can't
+// tell slugs apart.
+func slugHash(name string) string {
+	hash := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(hash[:4])
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tagConfig optionally describes a tag in the config.
+type tagConfig struct {
+	// Name is shown instead of the tag itself.
+	Name        string
+	Description string
+	// Slug overrides the generated file name of the tag.
+	Slug string
+}
+
+// tagInfo is a tag used by at least one article.
+type tagInfo struct {
+	// Tag matches the tags in article headers.
+	Tag string
+	// Slug is used for the file names of the index pages and feed.
+	Slug string
+	// Name defaults to the tag itself.
+	Name        string
+	Description string
+	Count       int
+}
+
+// collectTags gathers all tags used across the given articles, sorted by
+// tag. Names, descriptions and slugs are taken from the config. If the index
+// files of a tag clash with the ones of another tag or the main index, a
+// short hash of the tag is appended to its slug. Only clashes caused by the
+// config are an error.
+func collectTags(articles []*indexedArticle, tagConfigs map[string]tagConfig, slugs slugConfig, maxIndexEntries int) ([]*tagInfo, error) {
+	// Tags in articles are normalised, so the config has to be as well.
+	normalisedConfigs := make(map[string]tagConfig, len(tagConfigs))
+	for tag, config := range tagConfigs {
+		normalisedConfigs[strings.ToLower(strings.TrimSpace(tag))] = config
+	}
+
+	tagsByName := make(map[string]*tagInfo)
+	explicitSlugs := make(map[string]bool)
+	var tags []*tagInfo
+	for _, article := range articles {
+		for _, tag := range article.Tags {
+			info, exists := tagsByName[tag]
+			if !exists {
+				info = &tagInfo{Tag: tag, Name: tag, Slug: tag}
+				if config, configured := normalisedConfigs[tag]; configured {
+					if config.Name != "" {
+						info.Name = config.Name
+					}
+					if config.Slug != "" {
+						info.Slug = config.Slug
+						explicitSlugs[tag] = true
+					}
+					info.Description = config.Description
+				}
+				info.Slug = slugify(info.Slug, slugs)
+				tagsByName[tag] = info
+				tags = append(tags, info)
+			}
+			info.Count++
+		}
+	}
+
+	sort.Slice(tags, func(a, b int) bool {
+		return tags[a].Tag < tags[b].Tag
+	})
+
+	// Configured slugs are claimed first, followed by slugs identical to
+	// their tag, so that lossily derived slugs, which nobody chose
+	// deliberately, are the first to be changed.
+	claimOrder := func(info *tagInfo) int {
+		switch {
+		case explicitSlugs[info.Tag]:
+			return 0
+		case info.Slug == info.Tag:
+			return 1
+		}
+		return 2
+	}
+	claimingTags := append([]*tagInfo(nil), tags...)
+	sort.SliceStable(claimingTags, func(a, b int) bool {
+		return claimOrder(claimingTags[a]) < claimOrder(claimingTags[b])
+	})
+
+	// Each tag is written to index-<slug>.html, index-<slug>-2.html and so
+	// on. These files might clash with the ones of another tag, for example
+	// the third page of "python" and the tag "python 3", or with the pages
+	// of the main index, such as index-2.html. Files are identified by the
+	// part between "index-" and ".html".
+	pageCount := func(articleCount int) int {
+		return max(1, (articleCount+maxIndexEntries-1)/maxIndexEntries)
+	}
+	indexFiles := func(info *tagInfo) []string {
+		files := []string{info.Slug}
+		for page := 2; page <= pageCount(info.Count); page++ {
+			files = append(files, fmt.Sprintf("%s-%d", info.Slug, page))
+		}
+		return files
+	}
+	// The pages of the main index are owned by nil.
+	fileOwners := make(map[string]*tagInfo)
+	for page := 2; page <= pageCount(len(articles)); page++ {
+		fileOwners[strconv.Itoa(page)] = nil
+	}
+	findClash := func(info *tagInfo) (string, *tagInfo, bool) {
+		for _, file := range indexFiles(info) {
+			if owner, clashes := fileOwners[file]; clashes {
+				return file, owner, true
+			}
+		}
+		return "", nil, false
+	}
+
+	for _, info := range claimingTags {
+		if _, _, clashes := findClash(info); clashes && !explicitSlugs[info.Tag] {
+			info.Slug = slugify(info.Slug+" "+slugHash(info.Tag), slugs)
+		}
+		if file, other, clashes := findClash(info); clashes {
+			owner := "the main index"
+			if other != nil {
+				owner = fmt.Sprintf("tag '%s'", other.Tag)
+			}
+			return nil, fmt.Errorf("tag '%s' and %s both use the file 'index-%s.html', set a different Slug for the tag in the Tags config", info.Tag, owner, file)
+		}
+		for _, file := range indexFiles(info) {
+			fileOwners[file] = info
+		}
+	}
+
+	return tags, nil
+}
+
+type tagsPageData struct {
+	blogConfig
+	CustomPages []*customPageEntry
+	Tags        []*tagInfo
+}
+
+// writeTagsPage writes tags.html, which lists all tags with their number of
+// articles. Unlike the sidebar of the index, it's also available on mobile.
+func writeTagsPage(
+	theme *theme,
+	data tagsPageData,
+	outputDir string,
+	minifyOutput bool,
+	manifest *buildManifest,
+	sharedHash string,
+) error {
+	data.Title = data.Strings["Tags"]
+	file := data.LanguageDir + "tags.html"
+	if manifest.upToDate(file, hashInputs(sharedHash, data)) {
+		return nil
+	}
+
+	if err := writeTemplateToFile(theme.templates.Lookup("tags"), data, outputDir, file, minifyOutput); err != nil {
+		return fmt.Errorf("error writing tags page: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// theme contains the templates and assets used for a single build. By
+// default, these are the embedded skeletons, but each template and asset can
+// be overridden by a file in the theme directory.
+type theme struct {
+	// dir is the theme directory. It is optional and may not exist.
+	dir       string
+	templates *template.Template
+	// hash changes whenever either the skeletons or the theme change.
+	hash string
+}
+
+// loadTheme parses all templates in the given theme directory on top of the
+// embedded skeletons. Since templates are defined via `{{define "name"}}`,
+// a theme file only needs to define the templates it wants to replace, for
+// example `header` or `article`.
+func (builder *Builder) loadTheme(themeDir string) (*theme, error) {
+	templates, err := builder.skeletons.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't clone templates: %w", err)
+	}
+
+	loadedTheme := &theme{
+		dir:       themeDir,
+		templates: templates,
+		hash:      builder.templateHash,
+	}
+
+	if _, err := os.Stat(themeDir); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return loadedTheme, nil
+		}
+		return nil, err
+	}
+
+	themeHash, err := hashFS(os.DirFS(themeDir), ".")
+	if err != nil {
+		return nil, fmt.Errorf("couldn't hash theme: %w", err)
+	}
+	loadedTheme.hash = hashInputs(builder.templateHash, themeHash)
+
+	themeTemplates, err := filepath.Glob(filepath.Join(themeDir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+	if len(themeTemplates) > 0 {
+		if _, err := templates.ParseFiles(themeTemplates...); err != nil {
+			return nil, fmt.Errorf("couldn't parse theme templates: %w", err)
+		}
+	}
+
+	if *verbose {
+		log.Printf("Using theme '%s'.\n", themeDir)
+	}
+
+	return loadedTheme, nil
+}
+
+// openAsset opens a static file such as `base.css`. If the theme doesn't
+// contain the file, the embedded skeleton is used.
+func (theme *theme) openAsset(name string) (io.ReadCloser, error) {
+	if theme.dir != "" {
+		file, err := os.Open(filepath.Join(theme.dir, name))
+		if err == nil {
+			return file, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	return skeletonFS.Open(path.Join("skeletons", name))
+}
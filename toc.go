@@ -0,0 +1,43 @@
+package main
+
+// heading is a single `h2` to `h6` element, as found by transformHeading.
+type heading struct {
+	// Level is the number of the heading element, for example 2 for `h2`.
+	Level int
+	Id    string
+	Text  string
+}
+
+// tocEntry is a node of the nested outline rendered as table of contents.
+type tocEntry struct {
+	Id       string
+	Text     string
+	Children []*tocEntry
+}
+
+// buildTableOfContents nests the headings according to their levels. Skipped
+// levels, such as an `h4` directly following an `h2`, don't produce empty
+// entries, instead the heading is nested directly below the previous one.
+func buildTableOfContents(headings []heading) []*tocEntry {
+	type level struct {
+		level   int
+		entries *[]*tocEntry
+	}
+
+	var root []*tocEntry
+	// stack contains the entry lists that new headings can be appended to,
+	// from the outermost to the innermost.
+	stack := []level{{level: 0, entries: &root}}
+	for _, heading := range headings {
+		for len(stack) > 1 && stack[len(stack)-1].level >= heading.Level {
+			stack = stack[:len(stack)-1]
+		}
+
+		entry := &tocEntry{Id: heading.Id, Text: heading.Text}
+		parent := stack[len(stack)-1].entries
+		*parent = append(*parent, entry)
+		stack = append(stack, level{level: heading.Level, entries: &entry.Children})
+	}
+
+	return root
+}